@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/timebook/backend/internal/authz"
 	"github.com/timebook/backend/internal/config"
 	"github.com/timebook/backend/internal/db"
 	"github.com/timebook/backend/internal/handlers"
+	"github.com/timebook/backend/internal/jobs"
+	"github.com/timebook/backend/internal/jwtkeys"
 	"github.com/timebook/backend/internal/middleware"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/notify"
+	"github.com/timebook/backend/internal/oidc"
+	"github.com/timebook/backend/internal/password"
 )
 
 func main() {
@@ -30,8 +39,39 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Load JWT signing and verification keys
+	keys, err := jwtkeys.Load(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Load the password policy and breached-password list
+	passwords, err := password.NewPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+
+	// Initialize notification delivery
+	notifier, err := notify.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize notifier: %v", err)
+	}
+	sms, err := notify.NewSMSSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize SMS sender: %v", err)
+	}
+
+	// Initialize single sign-on (nil when OIDC_ISSUER_URL is unset)
+	oidcProvider := oidc.New(cfg, &http.Client{Timeout: 10 * time.Second})
+
 	// Initialize handlers
-	h := handlers.New(database, cfg)
+	h := handlers.New(database, cfg, keys, passwords, notifier, sms, oidcProvider)
+
+	// Mark confirmed appointments completed once they have ended
+	go jobs.Every(context.Background(), "auto-complete appointments", cfg.AppointmentCompleteEvery, h.AppointmentService.CompleteFinished)
+
+	// Delete slot holds that have expired
+	go jobs.Every(context.Background(), "sweep slot holds", cfg.SlotHoldSweepInterval, h.SlotHoldService.DeleteExpired)
 
 	// Setup routes
 	mux := http.NewServeMux()
@@ -39,12 +79,31 @@ func main() {
 	// Health check (no versioning)
 	mux.HandleFunc("GET /health", h.HealthCheck)
 
+	// Public keys for verifying tokens (no versioning)
+	mux.HandleFunc("GET /.well-known/jwks.json", h.JWKS)
+
 	// API v1 routes
 	// Public routes
 	mux.HandleFunc("POST /api/v1/auth/register", h.Register)
 	mux.HandleFunc("POST /api/v1/auth/login", h.Login)
+	mux.HandleFunc("POST /api/v1/auth/login/2fa", h.LoginTwoFactor)
+	mux.HandleFunc("POST /api/v1/auth/refresh", h.RefreshToken)
+	mux.HandleFunc("POST /api/v1/auth/logout", h.Logout)
+	mux.HandleFunc("POST /api/v1/auth/invitations/accept", h.AcceptInvitation)
+	mux.HandleFunc("POST /api/v1/auth/password/forgot", h.ForgotPassword)
+	mux.HandleFunc("POST /api/v1/auth/password/reset", h.ResetPassword)
+	mux.HandleFunc("POST /api/v1/auth/verify", h.VerifyEmail)
+	mux.HandleFunc("POST /api/v1/auth/email/confirm", h.ConfirmEmailChange)
+	mux.HandleFunc("GET /api/v1/auth/oidc/login", h.StartOIDCLogin)
+	mux.HandleFunc("POST /api/v1/auth/oidc/callback", h.CompleteOIDCLogin)
+	mux.HandleFunc("POST /api/v1/auth/phone/otp", h.RequestPhoneCode)
+	mux.HandleFunc("POST /api/v1/auth/phone/verify", h.VerifyPhoneCode)
 	mux.HandleFunc("GET /api/v1/services", h.GetServices)
 	mux.HandleFunc("GET /api/v1/services/{id}/slots", h.GetAvailableSlots)
+	mux.HandleFunc("POST /api/v1/guest/appointments", h.CreateGuestAppointment)
+	mux.HandleFunc("GET /api/v1/guest/appointments/manage", h.GetGuestAppointment)
+	mux.HandleFunc("POST /api/v1/guest/appointments/manage/confirm", h.ConfirmGuestAppointment)
+	mux.HandleFunc("POST /api/v1/guest/appointments/manage/cancel", h.CancelGuestAppointment)
 
 	// Legacy routes (redirect to v1) for backward compatibility
 	mux.HandleFunc("POST /api/auth/register", h.Register)
@@ -53,76 +112,115 @@ func main() {
 	mux.HandleFunc("GET /api/services/{id}/slots", h.GetAvailableSlots)
 
 	// Apply middleware
-	authMiddleware := middleware.AuthMiddleware(cfg.JWTSecret)
-	userMiddleware := middleware.RoleMiddleware("user")
-	masterMiddleware := middleware.RoleMiddleware("master")
-	adminMiddleware := middleware.RoleMiddleware("admin")
+	authMiddleware := middleware.AuthMiddleware(keys, h.AuthService, h.APIKeyService, h)
+	require := middleware.RequirePermission
+
+	// Account routes (any authenticated role) - v1
+	mux.HandleFunc("POST /api/v1/auth/verify/resend", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.ResendVerification))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/auth/password/change", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.ChangePassword))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/auth/2fa/enroll", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.EnrollTwoFactor))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/auth/2fa/verify", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.ActivateTwoFactor))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/auth/2fa/disable", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.DisableTwoFactor))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/auth/2fa/recovery-codes", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.RegenerateRecoveryCodes))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/auth/sessions", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.GetSessions))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/auth/sessions", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.RevokeOtherSessions))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/auth/sessions/{id}", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.RevokeSession))).ServeHTTP)
 
 	// User routes (protected) - v1
-	mux.HandleFunc("GET /api/v1/user/profile", authMiddleware(userMiddleware(http.HandlerFunc(h.GetUserProfile))).ServeHTTP)
-	mux.HandleFunc("POST /api/v1/appointments", authMiddleware(userMiddleware(http.HandlerFunc(h.CreateAppointment))).ServeHTTP)
-	mux.HandleFunc("GET /api/v1/appointments", authMiddleware(userMiddleware(http.HandlerFunc(h.GetAppointments))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/user/profile", authMiddleware(require(authz.PermProfileRead)(http.HandlerFunc(h.GetUserProfile))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/user/profile", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.UpdateUserProfile))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/user/export", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.ExportUserData))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/user", authMiddleware(require(authz.PermAccountManage)(http.HandlerFunc(h.DeleteAccount))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/appointments", authMiddleware(require(authz.PermBookingsCreate)(http.HandlerFunc(h.CreateAppointment))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/appointments", authMiddleware(require(authz.PermBookingsReadOwn)(http.HandlerFunc(h.GetAppointments))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/appointments/{id}/cancel", authMiddleware(require(authz.PermBookingsCancel)(http.HandlerFunc(h.CancelAppointment))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/holds", authMiddleware(require(authz.PermBookingsCreate)(http.HandlerFunc(h.CreateSlotHold))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/holds/{id}", authMiddleware(require(authz.PermBookingsCreate)(http.HandlerFunc(h.ReleaseSlotHold))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/appointments/{id}/reschedule", authMiddleware(require(authz.PermBookingsReschedule)(http.HandlerFunc(h.RescheduleAppointment))).ServeHTTP)
 
 	// Legacy user routes (backward compatibility)
-	mux.HandleFunc("GET /api/user/profile", authMiddleware(userMiddleware(http.HandlerFunc(h.GetUserProfile))).ServeHTTP)
-	mux.HandleFunc("POST /api/appointments", authMiddleware(userMiddleware(http.HandlerFunc(h.CreateAppointment))).ServeHTTP)
-	mux.HandleFunc("GET /api/appointments", authMiddleware(userMiddleware(http.HandlerFunc(h.GetAppointments))).ServeHTTP)
+	mux.HandleFunc("GET /api/user/profile", authMiddleware(require(authz.PermProfileRead)(http.HandlerFunc(h.GetUserProfile))).ServeHTTP)
+	mux.HandleFunc("POST /api/appointments", authMiddleware(require(authz.PermBookingsCreate)(http.HandlerFunc(h.CreateAppointment))).ServeHTTP)
+	mux.HandleFunc("GET /api/appointments", authMiddleware(require(authz.PermBookingsReadOwn)(http.HandlerFunc(h.GetAppointments))).ServeHTTP)
 
 	// Master routes (protected) - v1
-	mux.HandleFunc("GET /api/v1/master/profile", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetMasterProfile))).ServeHTTP)
-	mux.HandleFunc("POST /api/v1/master/services", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateService))).ServeHTTP)
-	mux.HandleFunc("GET /api/v1/master/services", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetMasterServices))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/master/services/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.UpdateService))).ServeHTTP)
-	mux.HandleFunc("DELETE /api/v1/master/services/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.DeleteService))).ServeHTTP)
-	mux.HandleFunc("GET /api/v1/master/appointments", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetMasterAppointments))).ServeHTTP)
-	mux.HandleFunc("POST /api/v1/master/appointments", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateAppointmentForClient))).ServeHTTP)
-	mux.HandleFunc("GET /api/v1/master/users/search", authMiddleware(masterMiddleware(http.HandlerFunc(h.SearchUsers))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/master/appointments/{id}/confirm", authMiddleware(masterMiddleware(http.HandlerFunc(h.ConfirmAppointment))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/master/appointments/{id}/reject", authMiddleware(masterMiddleware(http.HandlerFunc(h.RejectAppointment))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/master/profile", authMiddleware(require(authz.PermMasterProfileRead)(http.HandlerFunc(h.GetMasterProfile))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/profile", authMiddleware(require(authz.PermMasterProfileEdit)(http.HandlerFunc(h.UpdateMasterProfile))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/master/services", middleware.APIKeyScope(models.ScopeServicesWrite)(authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.CreateService)))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/master/services", middleware.APIKeyScope(models.ScopeServicesRead)(authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.GetMasterServices)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/services/{id}", middleware.APIKeyScope(models.ScopeServicesWrite)(authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.UpdateService)))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/master/services/{id}", middleware.APIKeyScope(models.ScopeServicesWrite)(authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.DeleteService)))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/master/appointments", middleware.APIKeyScope(models.ScopeAppointmentsRead)(authMiddleware(require(authz.PermMasterAppointmentsRead)(http.HandlerFunc(h.GetMasterAppointments)))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/master/appointments", middleware.APIKeyScope(models.ScopeAppointmentsWrite)(authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.CreateAppointmentForClient)))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/master/users/search", authMiddleware(require(authz.PermClientsSearch)(http.HandlerFunc(h.SearchUsers))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/appointments/{id}/confirm", middleware.APIKeyScope(models.ScopeAppointmentsWrite)(authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.ConfirmAppointment)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/appointments/{id}/reject", middleware.APIKeyScope(models.ScopeAppointmentsWrite)(authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.RejectAppointment)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/appointments/{id}/complete", middleware.APIKeyScope(models.ScopeAppointmentsWrite)(authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.CompleteAppointment)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/appointments/{id}/no-show", middleware.APIKeyScope(models.ScopeAppointmentsWrite)(authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.MarkAppointmentNoShow)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/appointments/{id}/reschedule", middleware.APIKeyScope(models.ScopeAppointmentsWrite)(authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.RescheduleMasterAppointment)))).ServeHTTP)
+
+	// Master API key routes (protected, JWT only) - v1
+	mux.HandleFunc("POST /api/v1/master/api-keys", authMiddleware(require(authz.PermAPIKeysManage)(http.HandlerFunc(h.CreateAPIKey))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/master/api-keys", authMiddleware(require(authz.PermAPIKeysManage)(http.HandlerFunc(h.GetAPIKeys))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/master/api-keys/{id}", authMiddleware(require(authz.PermAPIKeysManage)(http.HandlerFunc(h.RevokeAPIKey))).ServeHTTP)
 
 	// Master time slot routes (protected) - v1
-	mux.HandleFunc("POST /api/v1/master/time-slots", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateTimeSlot))).ServeHTTP)
-	mux.HandleFunc("GET /api/v1/master/time-slots", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetTimeSlots))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/master/time-slots/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.UpdateTimeSlot))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/master/time-slots/{id}/toggle-booking", authMiddleware(masterMiddleware(http.HandlerFunc(h.ToggleTimeSlotBooking))).ServeHTTP)
-	mux.HandleFunc("DELETE /api/v1/master/time-slots/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.DeleteTimeSlot))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/master/time-slots", middleware.APIKeyScope(models.ScopeTimeSlotsWrite)(authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.CreateTimeSlot)))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/master/time-slots", middleware.APIKeyScope(models.ScopeTimeSlotsRead)(authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.GetTimeSlots)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/time-slots/{id}", middleware.APIKeyScope(models.ScopeTimeSlotsWrite)(authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.UpdateTimeSlot)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/time-slots/{id}/toggle-booking", middleware.APIKeyScope(models.ScopeTimeSlotsWrite)(authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.ToggleTimeSlotBooking)))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/master/time-slots/{id}", middleware.APIKeyScope(models.ScopeTimeSlotsWrite)(authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.DeleteTimeSlot)))).ServeHTTP)
 
 	// Master service options (sub-categories) routes (protected) - v1
-	mux.HandleFunc("POST /api/v1/master/services/{id}/options", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateServiceOption))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/master/service-options/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.UpdateServiceOption))).ServeHTTP)
-	mux.HandleFunc("DELETE /api/v1/master/service-options/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.DeleteServiceOption))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/master/services/{id}/options", middleware.APIKeyScope(models.ScopeServicesWrite)(authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.CreateServiceOption)))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/master/service-options/{id}", middleware.APIKeyScope(models.ScopeServicesWrite)(authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.UpdateServiceOption)))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/master/service-options/{id}", middleware.APIKeyScope(models.ScopeServicesWrite)(authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.DeleteServiceOption)))).ServeHTTP)
 
 	// Legacy master routes (backward compatibility)
-	mux.HandleFunc("GET /api/master/profile", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetMasterProfile))).ServeHTTP)
-	mux.HandleFunc("POST /api/master/services", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateService))).ServeHTTP)
-	mux.HandleFunc("GET /api/master/services", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetMasterServices))).ServeHTTP)
-	mux.HandleFunc("PUT /api/master/services/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.UpdateService))).ServeHTTP)
-	mux.HandleFunc("DELETE /api/master/services/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.DeleteService))).ServeHTTP)
-	mux.HandleFunc("GET /api/master/appointments", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetMasterAppointments))).ServeHTTP)
-	mux.HandleFunc("POST /api/master/appointments", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateAppointmentForClient))).ServeHTTP)
-	mux.HandleFunc("GET /api/master/users/search", authMiddleware(masterMiddleware(http.HandlerFunc(h.SearchUsers))).ServeHTTP)
-	mux.HandleFunc("PUT /api/master/appointments/{id}/confirm", authMiddleware(masterMiddleware(http.HandlerFunc(h.ConfirmAppointment))).ServeHTTP)
-	mux.HandleFunc("PUT /api/master/appointments/{id}/reject", authMiddleware(masterMiddleware(http.HandlerFunc(h.RejectAppointment))).ServeHTTP)
-	mux.HandleFunc("POST /api/master/time-slots", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateTimeSlot))).ServeHTTP)
-	mux.HandleFunc("GET /api/master/time-slots", authMiddleware(masterMiddleware(http.HandlerFunc(h.GetTimeSlots))).ServeHTTP)
-	mux.HandleFunc("PUT /api/master/time-slots/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.UpdateTimeSlot))).ServeHTTP)
-	mux.HandleFunc("PUT /api/master/time-slots/{id}/toggle-booking", authMiddleware(masterMiddleware(http.HandlerFunc(h.ToggleTimeSlotBooking))).ServeHTTP)
-	mux.HandleFunc("DELETE /api/master/time-slots/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.DeleteTimeSlot))).ServeHTTP)
-	mux.HandleFunc("POST /api/master/services/{id}/options", authMiddleware(masterMiddleware(http.HandlerFunc(h.CreateServiceOption))).ServeHTTP)
-	mux.HandleFunc("PUT /api/master/service-options/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.UpdateServiceOption))).ServeHTTP)
-	mux.HandleFunc("DELETE /api/master/service-options/{id}", authMiddleware(masterMiddleware(http.HandlerFunc(h.DeleteServiceOption))).ServeHTTP)
+	mux.HandleFunc("GET /api/master/profile", authMiddleware(require(authz.PermMasterProfileRead)(http.HandlerFunc(h.GetMasterProfile))).ServeHTTP)
+	mux.HandleFunc("POST /api/master/services", authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.CreateService))).ServeHTTP)
+	mux.HandleFunc("GET /api/master/services", authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.GetMasterServices))).ServeHTTP)
+	mux.HandleFunc("PUT /api/master/services/{id}", authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.UpdateService))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/master/services/{id}", authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.DeleteService))).ServeHTTP)
+	mux.HandleFunc("GET /api/master/appointments", authMiddleware(require(authz.PermMasterAppointmentsRead)(http.HandlerFunc(h.GetMasterAppointments))).ServeHTTP)
+	mux.HandleFunc("POST /api/master/appointments", authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.CreateAppointmentForClient))).ServeHTTP)
+	mux.HandleFunc("GET /api/master/users/search", authMiddleware(require(authz.PermClientsSearch)(http.HandlerFunc(h.SearchUsers))).ServeHTTP)
+	mux.HandleFunc("PUT /api/master/appointments/{id}/confirm", authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.ConfirmAppointment))).ServeHTTP)
+	mux.HandleFunc("PUT /api/master/appointments/{id}/reject", authMiddleware(require(authz.PermMasterAppointmentsEdit)(http.HandlerFunc(h.RejectAppointment))).ServeHTTP)
+	mux.HandleFunc("POST /api/master/time-slots", authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.CreateTimeSlot))).ServeHTTP)
+	mux.HandleFunc("GET /api/master/time-slots", authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.GetTimeSlots))).ServeHTTP)
+	mux.HandleFunc("PUT /api/master/time-slots/{id}", authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.UpdateTimeSlot))).ServeHTTP)
+	mux.HandleFunc("PUT /api/master/time-slots/{id}/toggle-booking", authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.ToggleTimeSlotBooking))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/master/time-slots/{id}", authMiddleware(require(authz.PermTimeSlotsManage)(http.HandlerFunc(h.DeleteTimeSlot))).ServeHTTP)
+	mux.HandleFunc("POST /api/master/services/{id}/options", authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.CreateServiceOption))).ServeHTTP)
+	mux.HandleFunc("PUT /api/master/service-options/{id}", authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.UpdateServiceOption))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/master/service-options/{id}", authMiddleware(require(authz.PermServicesManage)(http.HandlerFunc(h.DeleteServiceOption))).ServeHTTP)
 
 	// Admin routes (protected) - v1
-	mux.HandleFunc("GET /api/v1/admin/masters", authMiddleware(adminMiddleware(http.HandlerFunc(h.GetMasters))).ServeHTTP)
-	mux.HandleFunc("GET /api/v1/admin/appointments", authMiddleware(adminMiddleware(http.HandlerFunc(h.GetAllAppointments))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/admin/appointments/{id}/confirm", authMiddleware(adminMiddleware(http.HandlerFunc(h.AdminConfirmAppointment))).ServeHTTP)
-	mux.HandleFunc("PUT /api/v1/admin/appointments/{id}/reject", authMiddleware(adminMiddleware(http.HandlerFunc(h.AdminRejectAppointment))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/admin/masters", authMiddleware(require(authz.PermMastersRead)(http.HandlerFunc(h.GetMasters))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/admin/appointments", authMiddleware(require(authz.PermAppointmentsManage)(http.HandlerFunc(h.GetAllAppointments))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/admin/appointments/{id}/confirm", authMiddleware(require(authz.PermAppointmentsManage)(http.HandlerFunc(h.AdminConfirmAppointment))).ServeHTTP)
+	mux.HandleFunc("PUT /api/v1/admin/appointments/{id}/reject", authMiddleware(require(authz.PermAppointmentsManage)(http.HandlerFunc(h.AdminRejectAppointment))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/admin/invitations", authMiddleware(require(authz.PermInvitationsManage)(http.HandlerFunc(h.CreateInvitation))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/admin/invitations", authMiddleware(require(authz.PermInvitationsManage)(http.HandlerFunc(h.GetInvitations))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/admin/invitations/{id}", authMiddleware(require(authz.PermInvitationsManage)(http.HandlerFunc(h.RevokeInvitation))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/admin/users", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminListUsers))).ServeHTTP)
+	mux.HandleFunc("GET /api/v1/admin/users/{id}", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminGetUser))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/admin/users/{id}", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminDeleteUser))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/admin/users/{id}/restore", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminRestoreUser))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/admin/users/{id}/suspend", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminSuspendUser))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/admin/users/{id}/unsuspend", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminUnsuspendUser))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/admin/users/{id}/unlock", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminUnlockUser))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/admin/users/{id}/roles", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminGrantRole))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/admin/users/{id}/roles/{role}", authMiddleware(require(authz.PermUsersManage)(http.HandlerFunc(h.AdminRevokeRole))).ServeHTTP)
+	mux.HandleFunc("POST /api/v1/admin/users/{id}/impersonate", authMiddleware(require(authz.PermUsersImpersonate)(http.HandlerFunc(h.StartImpersonation))).ServeHTTP)
+	mux.HandleFunc("DELETE /api/v1/admin/impersonations/{id}", authMiddleware(require(authz.PermUsersImpersonate)(http.HandlerFunc(h.EndImpersonation))).ServeHTTP)
 
 	// Legacy admin routes (backward compatibility)
-	mux.HandleFunc("GET /api/admin/masters", authMiddleware(adminMiddleware(http.HandlerFunc(h.GetMasters))).ServeHTTP)
-	mux.HandleFunc("GET /api/admin/appointments", authMiddleware(adminMiddleware(http.HandlerFunc(h.GetAllAppointments))).ServeHTTP)
-	mux.HandleFunc("PUT /api/admin/appointments/{id}/confirm", authMiddleware(adminMiddleware(http.HandlerFunc(h.AdminConfirmAppointment))).ServeHTTP)
-	mux.HandleFunc("PUT /api/admin/appointments/{id}/reject", authMiddleware(adminMiddleware(http.HandlerFunc(h.AdminRejectAppointment))).ServeHTTP)
+	mux.HandleFunc("GET /api/admin/masters", authMiddleware(require(authz.PermMastersRead)(http.HandlerFunc(h.GetMasters))).ServeHTTP)
+	mux.HandleFunc("GET /api/admin/appointments", authMiddleware(require(authz.PermAppointmentsManage)(http.HandlerFunc(h.GetAllAppointments))).ServeHTTP)
+	mux.HandleFunc("PUT /api/admin/appointments/{id}/confirm", authMiddleware(require(authz.PermAppointmentsManage)(http.HandlerFunc(h.AdminConfirmAppointment))).ServeHTTP)
+	mux.HandleFunc("PUT /api/admin/appointments/{id}/reject", authMiddleware(require(authz.PermAppointmentsManage)(http.HandlerFunc(h.AdminRejectAppointment))).ServeHTTP)
 
 	// CORS middleware with configured origins
 	handler := middleware.CORSMiddleware(cfg.CORSAllowedOrigins)(mux)
@@ -0,0 +1,116 @@
+// Package authz defines the permissions routes are protected by and the
+// roles that grant them. A user holding several roles has the union of
+// their permissions.
+package authz
+
+import "github.com/timebook/backend/internal/models"
+
+// Permission names a capability checked by middleware.RequirePermission
+type Permission string
+
+const (
+	// Any signed-in account
+	PermAccountManage Permission = "account:manage"
+
+	// Clients
+	PermProfileRead        Permission = "profile:read"
+	PermBookingsCreate     Permission = "bookings:create"
+	PermBookingsReadOwn    Permission = "bookings:read_own"
+	PermBookingsCancel     Permission = "bookings:cancel_own"
+	PermBookingsReschedule Permission = "bookings:reschedule_own"
+
+	// Masters
+	PermMasterProfileRead      Permission = "master_profile:read"
+	PermMasterProfileEdit      Permission = "master_profile:write"
+	PermServicesManage         Permission = "services:manage"
+	PermTimeSlotsManage        Permission = "time_slots:manage"
+	PermMasterAppointmentsRead Permission = "master_appointments:read"
+	PermMasterAppointmentsEdit Permission = "master_appointments:write"
+	PermClientsSearch          Permission = "clients:search"
+	PermAPIKeysManage          Permission = "api_keys:manage"
+
+	// Admins
+	PermMastersRead        Permission = "masters:read"
+	PermAppointmentsManage Permission = "appointments:manage"
+	PermInvitationsManage  Permission = "invitations:manage"
+	PermUsersManage        Permission = "users:manage"
+	PermUsersImpersonate   Permission = "users:impersonate"
+)
+
+// rolePermissions lists the permissions each role grants
+var rolePermissions = map[models.UserRole][]Permission{
+	models.RoleUser: {
+		PermAccountManage,
+		PermProfileRead,
+		PermBookingsCreate,
+		PermBookingsReadOwn,
+		PermBookingsCancel,
+		PermBookingsReschedule,
+	},
+	models.RoleMaster: {
+		PermAccountManage,
+		PermMasterProfileRead,
+		PermMasterProfileEdit,
+		PermServicesManage,
+		PermTimeSlotsManage,
+		PermMasterAppointmentsRead,
+		PermMasterAppointmentsEdit,
+		PermClientsSearch,
+		PermAPIKeysManage,
+	},
+	models.RoleAdmin: {
+		PermAccountManage,
+		PermMastersRead,
+		PermAppointmentsManage,
+		PermInvitationsManage,
+		PermUsersManage,
+		PermUsersImpersonate,
+	},
+}
+
+// impersonationDenied lists permissions an admin does not get while acting
+// as another user: they would let the admin take over the account rather
+// than just look at it
+var impersonationDenied = map[Permission]bool{
+	PermAccountManage: true,
+	PermAPIKeysManage: true,
+}
+
+// IsRole reports whether role is a known role
+func IsRole(role models.UserRole) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether any of the roles grants the permission
+func HasPermission(roles []string, permission Permission) bool {
+	for _, role := range roles {
+		for _, p := range rolePermissions[models.UserRole(role)] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AllowedWhileImpersonating reports whether a request made with an
+// impersonation token may use the permission
+func AllowedWhileImpersonating(permission Permission) bool {
+	return !impersonationDenied[permission]
+}
+
+// Permissions returns the permissions granted by the roles, without duplicates
+func Permissions(roles []string) []Permission {
+	seen := map[Permission]bool{}
+	var permissions []Permission
+	for _, role := range roles {
+		for _, p := range rolePermissions[models.UserRole(role)] {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	return permissions
+}
@@ -2,34 +2,119 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	DBHost             string
-	DBPort             string
-	DBUser             string
-	DBPassword         string
-	DBName             string
-	DBSSLMode          string
-	ServerPort         string
-	ServerHost         string
-	JWTSecret          string
-	JWTExpirationHours int
-	CORSAllowedOrigins []string
-	Environment        string
+	DBHost                   string
+	DBPort                   string
+	DBUser                   string
+	DBPassword               string
+	DBName                   string
+	DBSSLMode                string
+	ServerPort               string
+	ServerHost               string
+	JWTSecret                string
+	JWTSigningKeyFile        string
+	JWTPreviousKeyFiles      []string
+	JWTPreviousKeysRetireAt  time.Time
+	AccessTokenTTL           time.Duration
+	RefreshTokenTTL          time.Duration
+	InviteTTL                time.Duration
+	PasswordResetTTL         time.Duration
+	VerificationTTL          time.Duration
+	RequireVerifiedEmail     bool
+	LoginMaxAttempts         int
+	LoginMaxAttemptsPerIP    int
+	LoginAttemptWindow       time.Duration
+	LoginBackoffBase         time.Duration
+	LoginLockoutDuration     time.Duration
+	TrustProxyHeaders        bool
+	MFAChallengeTTL          time.Duration
+	MFAChallengeMaxAttempts  int
+	ImpersonationTTL         time.Duration
+	PasswordMinLength        int
+	PasswordBreachedListFile string
+	BcryptCost               int
+	ReauthMaxAge             time.Duration
+	OIDCIssuerURL            string
+	OIDCClientID             string
+	OIDCClientSecret         string
+	OIDCRedirectURL          string
+	OIDCScopes               []string
+	OIDCAllowSignup          bool
+	OIDCStateTTL             time.Duration
+	FrontendURL              string
+	NotifierDriver           string
+	NotifierFilePath         string
+	SMSDriver                string
+	SMSFilePath              string
+	PhoneDefaultCountryCode  string
+	PhoneOTPTTL              time.Duration
+	PhoneOTPMaxAttempts      int
+	PhoneOTPResendInterval   time.Duration
+	PhoneOTPSendWindow       time.Duration
+	PhoneOTPMaxSendsPerPhone int
+	PhoneOTPMaxSendsPerIP    int
+	GuestBookingEnabled      bool
+	GuestManageTokenGrace    time.Duration
+	GuestBookingWindow       time.Duration
+	GuestBookingMaxPerEmail  int
+	GuestBookingMaxPerIP     int
+	AppointmentCompleteEvery time.Duration
+	SlotHoldTTL              time.Duration
+	SlotHoldSweepInterval    time.Duration
+	DefaultTimeZone          string
+	CORSAllowedOrigins       []string
+	Environment              string
 }
 
 func Load() (*Config, error) {
 	env := getEnv("ENVIRONMENT", "development")
 	jwtSecret := getEnv("JWT_SECRET", "change-me-in-production")
+	signingKeyFile := getEnv("JWT_SIGNING_KEY_FILE", "")
 
-	// Validate JWT secret in production
-	if env == "production" && (jwtSecret == "" || jwtSecret == "change-me-in-production") {
+	// Validate JWT secret in production (only needed without a signing key)
+	if env == "production" && signingKeyFile == "" && (jwtSecret == "" || jwtSecret == "change-me-in-production") {
 		return nil, errors.New("JWT_SECRET must be set to a secure value in production")
 	}
 
+	// Previous verification keys are accepted until the overlap window ends
+	var previousKeyFiles []string
+	for _, path := range strings.Split(getEnv("JWT_PREVIOUS_KEY_FILES", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			previousKeyFiles = append(previousKeyFiles, path)
+		}
+	}
+	var previousKeysRetireAt time.Time
+	if value := getEnv("JWT_PREVIOUS_KEYS_RETIRE_AT", ""); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("JWT_PREVIOUS_KEYS_RETIRE_AT must be an RFC 3339 timestamp")
+		}
+		previousKeysRetireAt = t
+	}
+
+	frontendURL := strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:5173"), "/")
+
+	// OpenID Connect single sign-on is enabled by setting an issuer
+	oidcIssuer := getEnv("OIDC_ISSUER_URL", "")
+	oidcClientID := getEnv("OIDC_CLIENT_ID", "")
+	if oidcIssuer != "" && oidcClientID == "" {
+		return nil, errors.New("OIDC_CLIENT_ID must be set when OIDC_ISSUER_URL is configured")
+	}
+	oidcScopes := strings.Fields(getEnv("OIDC_SCOPES", "openid email profile"))
+
+	// Working hours of masters without a time zone are read in this zone
+	defaultTimeZone := getEnv("DEFAULT_TIME_ZONE", "UTC")
+	if _, err := time.LoadLocation(defaultTimeZone); err != nil {
+		return nil, fmt.Errorf("DEFAULT_TIME_ZONE %q is not a known time zone: %w", defaultTimeZone, err)
+	}
+
 	// Parse CORS origins from environment variable
 	corsOrigins := getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:5173,http://localhost:3000")
 	allowedOrigins := strings.Split(corsOrigins, ",")
@@ -38,18 +123,67 @@ func Load() (*Config, error) {
 	}
 
 	return &Config{
-		DBHost:             getEnv("DB_HOST", "localhost"),
-		DBPort:             getEnv("DB_PORT", "5432"),
-		DBUser:             getEnv("DB_USER", "timebook"),
-		DBPassword:         getEnv("DB_PASSWORD", "timebook"),
-		DBName:             getEnv("DB_NAME", "timebook"),
-		DBSSLMode:          getEnv("DB_SSLMODE", "disable"),
-		ServerPort:         getEnv("SERVER_PORT", "8080"),
-		ServerHost:         getEnv("SERVER_HOST", "0.0.0.0"),
-		JWTSecret:          jwtSecret,
-		JWTExpirationHours: 24,
-		CORSAllowedOrigins: allowedOrigins,
-		Environment:        env,
+		DBHost:                   getEnv("DB_HOST", "localhost"),
+		DBPort:                   getEnv("DB_PORT", "5432"),
+		DBUser:                   getEnv("DB_USER", "timebook"),
+		DBPassword:               getEnv("DB_PASSWORD", "timebook"),
+		DBName:                   getEnv("DB_NAME", "timebook"),
+		DBSSLMode:                getEnv("DB_SSLMODE", "disable"),
+		ServerPort:               getEnv("SERVER_PORT", "8080"),
+		ServerHost:               getEnv("SERVER_HOST", "0.0.0.0"),
+		JWTSecret:                jwtSecret,
+		JWTSigningKeyFile:        signingKeyFile,
+		JWTPreviousKeyFiles:      previousKeyFiles,
+		JWTPreviousKeysRetireAt:  previousKeysRetireAt,
+		AccessTokenTTL:           getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:          getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		InviteTTL:                getEnvDuration("INVITE_TTL", 72*time.Hour),
+		PasswordResetTTL:         getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		VerificationTTL:          getEnvDuration("VERIFICATION_TTL", 48*time.Hour),
+		RequireVerifiedEmail:     getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+		LoginMaxAttempts:         getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginMaxAttemptsPerIP:    getEnvInt("LOGIN_MAX_ATTEMPTS_PER_IP", 50),
+		LoginAttemptWindow:       getEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+		LoginBackoffBase:         getEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginLockoutDuration:     getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		TrustProxyHeaders:        getEnvBool("TRUST_PROXY_HEADERS", false),
+		MFAChallengeTTL:          getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		MFAChallengeMaxAttempts:  getEnvInt("MFA_CHALLENGE_MAX_ATTEMPTS", 5),
+		ImpersonationTTL:         getEnvDuration("IMPERSONATION_TTL", 30*time.Minute),
+		PasswordMinLength:        getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordBreachedListFile: getEnv("PASSWORD_BREACHED_LIST_FILE", ""),
+		BcryptCost:               getEnvInt("BCRYPT_COST", 12),
+		ReauthMaxAge:             getEnvDuration("REAUTH_MAX_AGE", 10*time.Minute),
+		OIDCIssuerURL:            oidcIssuer,
+		OIDCClientID:             oidcClientID,
+		OIDCClientSecret:         getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:          getEnv("OIDC_REDIRECT_URL", frontendURL+"/auth/oidc/callback"),
+		OIDCScopes:               oidcScopes,
+		OIDCAllowSignup:          getEnvBool("OIDC_ALLOW_SIGNUP", true),
+		OIDCStateTTL:             getEnvDuration("OIDC_STATE_TTL", 10*time.Minute),
+		FrontendURL:              frontendURL,
+		NotifierDriver:           getEnv("NOTIFIER_DRIVER", "log"),
+		NotifierFilePath:         getEnv("NOTIFIER_FILE_PATH", "notifications.log"),
+		SMSDriver:                getEnv("SMS_DRIVER", "log"),
+		SMSFilePath:              getEnv("SMS_FILE_PATH", "sms.log"),
+		PhoneDefaultCountryCode:  getEnv("PHONE_DEFAULT_COUNTRY_CODE", ""),
+		PhoneOTPTTL:              getEnvDuration("PHONE_OTP_TTL", 5*time.Minute),
+		PhoneOTPMaxAttempts:      getEnvInt("PHONE_OTP_MAX_ATTEMPTS", 5),
+		PhoneOTPResendInterval:   getEnvDuration("PHONE_OTP_RESEND_INTERVAL", time.Minute),
+		PhoneOTPSendWindow:       getEnvDuration("PHONE_OTP_SEND_WINDOW", time.Hour),
+		PhoneOTPMaxSendsPerPhone: getEnvInt("PHONE_OTP_MAX_SENDS_PER_PHONE", 5),
+		PhoneOTPMaxSendsPerIP:    getEnvInt("PHONE_OTP_MAX_SENDS_PER_IP", 20),
+		GuestBookingEnabled:      getEnvBool("GUEST_BOOKING_ENABLED", true),
+		GuestManageTokenGrace:    getEnvDuration("GUEST_MANAGE_TOKEN_GRACE", 7*24*time.Hour),
+		GuestBookingWindow:       getEnvDuration("GUEST_BOOKING_WINDOW", time.Hour),
+		GuestBookingMaxPerEmail:  getEnvInt("GUEST_BOOKING_MAX_PER_EMAIL", 3),
+		GuestBookingMaxPerIP:     getEnvInt("GUEST_BOOKING_MAX_PER_IP", 10),
+		AppointmentCompleteEvery: getEnvDuration("APPOINTMENT_AUTO_COMPLETE_INTERVAL", 5*time.Minute),
+		SlotHoldTTL:              getEnvDuration("SLOT_HOLD_TTL", 10*time.Minute),
+		SlotHoldSweepInterval:    getEnvDuration("SLOT_HOLD_SWEEP_INTERVAL", time.Minute),
+		DefaultTimeZone:          defaultTimeZone,
+		CORSAllowedOrigins:       allowedOrigins,
+		Environment:              env,
 	}, nil
 }
 
@@ -59,3 +193,30 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
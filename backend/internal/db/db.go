@@ -39,6 +39,22 @@ func Initialize(cfg *config.Config) (*gorm.DB, error) {
 		&models.Appointment{},
 		&models.TimeSlot{},
 		&models.ServiceOption{},
+		&models.RefreshToken{},
+		&models.Invitation{},
+		&models.UserToken{},
+		&models.LoginThrottle{},
+		&models.AuditLog{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
+		&models.OIDCLoginState{},
+		&models.UserIdentity{},
+		&models.Session{},
+		&models.RoleGrant{},
+		&models.Impersonation{},
+		&models.PhoneLoginCode{},
+		&models.Guest{},
+		&models.AppointmentTransition{},
+		&models.SlotHold{},
 	); err != nil {
 		log.Printf("AutoMigrate warning: %v", err)
 	}
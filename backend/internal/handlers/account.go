@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/timebook/backend/internal/services"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPassword sends a password reset link if the email belongs to an account.
+// The response is the same either way.
+func (h *Handlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.AccountService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		respondWithServiceError(w, err, "Failed to request password reset")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "If an account exists for this email, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword
+func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.AccountService.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		respondWithServiceError(w, err, "Failed to reset password")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
+
+// ChangePassword sets a new password for the current user after checking
+// the current one. Wrong guesses count towards the login lockout.
+func (h *Handlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	email, _ := r.Context().Value("user_email").(string)
+	ip := h.clientIP(r)
+	wait, err := h.LoginThrottleService.Check(r.Context(), email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed attempts. Please try again later.")
+		return
+	}
+
+	err = h.AccountService.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword, getContextSessionID(r))
+	if err != nil {
+		if errors.Is(err, services.ErrIncorrectPassword) {
+			h.recordLoginFailure(r, email, ip)
+		}
+		respondWithServiceError(w, err, "Failed to change password")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Password changed"})
+}
+
+// VerifyEmail confirms the user's email address using a token from the verification link
+func (h *Handlers) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.AccountService.VerifyEmail(r.Context(), req.Token)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to verify email")
+		return
+	}
+
+	user.Password = ""
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// ConfirmEmailChange switches the user to the new email address using a token
+// from the link sent there
+func (h *Handlers) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.AccountService.ConfirmEmailChange(r.Context(), req.Token, h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to change email")
+		return
+	}
+
+	user.Password = ""
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// ResendVerification sends a fresh verification link to the logged-in user
+func (h *Handlers) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.AccountService.ResendVerification(r.Context(), userID); err != nil {
+		respondWithServiceError(w, err, "Failed to send verification email")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
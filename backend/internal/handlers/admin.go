@@ -18,7 +18,7 @@ func (h *Handlers) GetMasters(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handlers) GetAllAppointments(w http.ResponseWriter, r *http.Request) {
 	var appointments []models.Appointment
-	query := h.DB.Preload("User").Preload("Service").Preload("Master").Preload("Master.User")
+	query := h.DB.Preload("User").Preload("Guest").Preload("Service").Preload("Master").Preload("Master.User")
 
 	// Filter by master if provided
 	if masterID := r.URL.Query().Get("master_id"); masterID != "" {
@@ -39,6 +39,11 @@ func (h *Handlers) GetAllAppointments(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) AdminConfirmAppointment(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
 	appointmentID, err := getIDParam(r)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
@@ -53,9 +58,9 @@ func (h *Handlers) AdminConfirmAppointment(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Use service layer to confirm appointment with transaction
-	confirmedAppointment, err := h.AppointmentService.ConfirmAppointment(r.Context(), appointmentID)
+	confirmedAppointment, err := h.AppointmentService.ConfirmAppointment(r.Context(), adminID, appointmentID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to confirm appointment")
+		respondWithServiceError(w, err, "Failed to confirm appointment")
 		return
 	}
 
@@ -63,6 +68,11 @@ func (h *Handlers) AdminConfirmAppointment(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *Handlers) AdminRejectAppointment(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
 	appointmentID, err := getIDParam(r)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
@@ -76,12 +86,38 @@ func (h *Handlers) AdminRejectAppointment(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	reason, ok := decodeStatusReason(w, r)
+	if !ok {
+		return
+	}
+
 	// Use service layer to reject appointment with transaction
-	rejectedAppointment, err := h.AppointmentService.RejectAppointment(r.Context(), appointmentID)
+	rejectedAppointment, err := h.AppointmentService.RejectAppointment(r.Context(), adminID, appointmentID, reason)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to reject appointment")
+		respondWithServiceError(w, err, "Failed to reject appointment")
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, rejectedAppointment)
 }
+
+// AdminUnlockUser lifts a login lockout on a user's account
+func (h *Handlers) AdminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.LoginThrottleService.Unlock(r.Context(), adminID, userID, h.clientIP(r)); err != nil {
+		respondWithServiceError(w, err, "Failed to unlock user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "User unlocked"})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/timebook/backend/internal/models"
+)
+
+type GrantRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UserRolesResponse lists every role a user holds, primary role first
+type UserRolesResponse struct {
+	UserID uint              `json:"user_id"`
+	Role   models.UserRole   `json:"role"`
+	Roles  []models.UserRole `json:"roles"`
+}
+
+// AdminGrantRole gives a user an additional role
+func (h *Handlers) AdminGrantRole(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req GrantRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.RoleService.GrantRole(r.Context(), adminID, userID, models.UserRole(req.Role), h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to grant role")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newUserRolesResponse(user))
+}
+
+// AdminRevokeRole removes a role previously granted to a user
+func (h *Handlers) AdminRevokeRole(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.RoleService.RevokeRole(r.Context(), adminID, userID, models.UserRole(r.PathValue("role")), h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to revoke role")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newUserRolesResponse(user))
+}
+
+func newUserRolesResponse(user *models.User) UserRolesResponse {
+	return UserRolesResponse{
+		UserID: user.ID,
+		Role:   user.Role,
+		Roles:  user.Roles(),
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+)
+
+// Admin user list paging
+const (
+	defaultUsersPerPage = 20
+	maxUsersPerPage     = 100
+)
+
+// AdminUser is a user as shown to admins, including roles and deletion time
+type AdminUser struct {
+	*models.User
+	Roles     []models.UserRole `json:"roles"`
+	DeletedAt *time.Time        `json:"deleted_at,omitempty"`
+}
+
+type AdminUserListResponse struct {
+	Users   []AdminUser `json:"users"`
+	Total   int64       `json:"total"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+}
+
+type AdminUserDetailResponse struct {
+	AdminUser
+	MasterProfile *models.MasterProfile `json:"master_profile,omitempty"`
+	Sessions      []*models.Session     `json:"sessions"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AdminListUsers searches all users by name, email or phone, optionally
+// filtered by role and status (active, suspended or deleted)
+func (h *Handlers) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, err := positiveIntParam(query.Get("page"), 1)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid page")
+		return
+	}
+	perPage, err := positiveIntParam(query.Get("per_page"), defaultUsersPerPage)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid per_page")
+		return
+	}
+	if perPage > maxUsersPerPage {
+		perPage = maxUsersPerPage
+	}
+
+	users, total, err := h.UserAdminService.ListUsers(r.Context(), repositories.UserFilter{
+		Query:  query.Get("q"),
+		Role:   models.UserRole(query.Get("role")),
+		Status: query.Get("status"),
+		Offset: (page - 1) * perPage,
+		Limit:  perPage,
+	})
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to fetch users")
+		return
+	}
+
+	resp := AdminUserListResponse{
+		Users:   make([]AdminUser, 0, len(users)),
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}
+	for _, user := range users {
+		resp.Users = append(resp.Users, newAdminUser(user))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// AdminGetUser shows a user's account, roles, master profile and sessions
+func (h *Handlers) AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	detail, err := h.UserAdminService.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to fetch user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, AdminUserDetailResponse{
+		AdminUser:     newAdminUser(detail.User),
+		MasterProfile: detail.MasterProfile,
+		Sessions:      detail.Sessions,
+	})
+}
+
+// AdminSuspendUser blocks a user from logging in and signs them out
+func (h *Handlers) AdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// The reason is optional, so an empty body is fine
+	var req SuspendUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.UserAdminService.Suspend(r.Context(), adminID, userID, req.Reason, h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to suspend user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAdminUser(user))
+}
+
+// AdminUnsuspendUser lets a suspended user log in again
+func (h *Handlers) AdminUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.UserAdminService.Unsuspend(r.Context(), adminID, userID, h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to unsuspend user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAdminUser(user))
+}
+
+// AdminDeleteUser soft-deletes a user; the account can be restored
+func (h *Handlers) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.UserAdminService.Delete(r.Context(), adminID, userID, h.clientIP(r)); err != nil {
+		respondWithServiceError(w, err, "Failed to delete user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "User deleted"})
+}
+
+// AdminRestoreUser undoes the soft delete of a user
+func (h *Handlers) AdminRestoreUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.UserAdminService.Restore(r.Context(), adminID, userID, h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to restore user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAdminUser(user))
+}
+
+func newAdminUser(user *models.User) AdminUser {
+	user.Password = ""
+	admin := AdminUser{User: user, Roles: user.Roles()}
+	if user.DeletedAt.Valid {
+		admin.DeletedAt = &user.DeletedAt.Time
+	}
+	return admin
+}
+
+// positiveIntParam parses an optional positive integer query parameter
+func positiveIntParam(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, errors.New("must be a positive integer")
+	}
+	return n, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/services"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyResponse struct {
+	APIKey *models.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+// CreateAPIKey creates a personal API key for the current master.
+// The key is returned once and cannot be retrieved later.
+func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	apiKey, key, err := h.APIKeyService.CreateAPIKey(r.Context(), userID, services.CreateAPIKeyInput{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to create API key")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, APIKeyResponse{
+		APIKey: apiKey,
+		Key:    key,
+	})
+}
+
+// GetAPIKeys lists the current master's API keys
+func (h *Handlers) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.APIKeyService.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch API keys")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+// RevokeAPIKey permanently disables one of the current master's API keys
+func (h *Handlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keyID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.APIKeyService.RevokeAPIKey(r.Context(), userID, keyID); err != nil {
+		respondWithServiceError(w, err, "Failed to revoke API key")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "API key revoked"})
+}
@@ -2,13 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/timebook/backend/internal/middleware"
 	"github.com/timebook/backend/internal/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/timebook/backend/internal/services"
 )
 
 type RegisterRequest struct {
@@ -24,9 +25,16 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string            `json:"token"`
+	RefreshToken string            `json:"refresh_token"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+	User         models.User       `json:"user"`
+	Roles        []models.UserRole `json:"roles"`
 }
 
 func (h *Handlers) Register(w http.ResponseWriter, r *http.Request) {
@@ -36,16 +44,34 @@ func (h *Handlers) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate role
+	// Validate role. Admin accounts can only be created through an invitation.
 	role := models.RoleUser
-	if req.Role == "master" {
+	switch req.Role {
+	case "", "user":
+	case "master":
 		role = models.RoleMaster
-	} else if req.Role == "admin" {
-		role = models.RoleAdmin
+	default:
+		respondWithError(w, http.StatusBadRequest, "Role must be user or master")
+		return
+	}
+
+	if err := h.Passwords.Validate(req.Password); err != nil {
+		respondWithServiceError(w, err, "Invalid password")
+		return
+	}
+
+	phone, err := h.PhoneLoginService.NormalizePhone(req.Phone)
+	if err != nil {
+		respondWithServiceError(w, err, "Invalid phone number")
+		return
+	}
+	if err := h.PhoneLoginService.CheckPhoneFree(r.Context(), phone, 0); err != nil {
+		respondWithServiceError(w, err, "Failed to create user")
+		return
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := h.Passwords.Hash(req.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
 		return
@@ -53,9 +79,9 @@ func (h *Handlers) Register(w http.ResponseWriter, r *http.Request) {
 
 	user := models.User{
 		Email:    req.Email,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
 		Name:     req.Name,
-		Phone:    req.Phone,
+		Phone:    phone,
 		Role:     role,
 	}
 
@@ -72,18 +98,20 @@ func (h *Handlers) Register(w http.ResponseWriter, r *http.Request) {
 		h.DB.Create(&masterProfile)
 	}
 
-	// Generate token
-	token, err := generateToken(user, h.Config.JWTSecret)
+	// Ask the user to confirm their email address
+	if err := h.AccountService.SendVerification(r.Context(), &user); err != nil {
+		log.Printf("register: failed to send verification to user %d: %v", user.ID, err)
+	}
+
+	// Generate tokens
+	tokens, err := h.AuthService.IssueTokens(r.Context(), &user, h.clientInfo(r))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
 	user.Password = "" // Don't send password back
-	respondWithJSON(w, http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	respondWithJSON(w, http.StatusCreated, newAuthResponse(tokens, user))
 }
 
 func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
@@ -93,44 +121,137 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := h.clientIP(r)
+	wait, err := h.LoginThrottleService.Check(r.Context(), req.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts. Please try again later.")
+		return
+	}
+
 	var user models.User
 	if err := h.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		h.recordLoginFailure(r, req.Email, ip)
 		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if !h.Passwords.Compare(user.Password, req.Password) {
+		h.recordLoginFailure(r, req.Email, ip)
 		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	// Generate token
-	token, err := generateToken(user, h.Config.JWTSecret)
+	// Accounts with two-factor authentication are cleared in LoginTwoFactor
+	if err := h.LoginThrottleService.RecordFirstFactor(r.Context(), &user); err != nil {
+		log.Printf("login: failed to reset attempts for user %d: %v", user.ID, err)
+	}
+
+	// Hashes made at an older bcrypt cost are upgraded while we have the password
+	if err := h.AccountService.UpgradePasswordHash(r.Context(), &user, req.Password); err != nil {
+		log.Printf("login: failed to upgrade password hash for user %d: %v", user.ID, err)
+	}
+
+	h.respondWithLogin(w, r, &user)
+}
+
+// respondWithLogin finishes a successful first factor: suspended accounts
+// are refused, accounts with two-factor authentication get a challenge and
+// everyone else gets tokens
+func (h *Handlers) respondWithLogin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if user.Suspended() {
+		respondWithServiceError(w, services.ErrAccountSuspended, "Failed to log in")
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		challenge, expiresAt, err := h.MFAService.NewChallenge(user)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    challenge,
+			ExpiresAt:   expiresAt,
+		})
+		return
+	}
+
+	// Generate tokens
+	tokens, err := h.AuthService.IssueTokens(r.Context(), user, h.clientInfo(r))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		respondWithServiceError(w, err, "Failed to generate token")
 		return
 	}
 
 	user.Password = "" // Don't send password back
-	respondWithJSON(w, http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	respondWithJSON(w, http.StatusOK, newAuthResponse(tokens, *user))
+}
+
+// recordLoginFailure counts a failed login; errors are logged rather than
+// surfaced so the caller still gets a plain "invalid credentials" answer
+func (h *Handlers) recordLoginFailure(r *http.Request, email, ip string) {
+	if err := h.LoginThrottleService.RecordFailure(r.Context(), email, ip); err != nil {
+		log.Printf("login: failed to record failed attempt: %v", err)
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+// The presented refresh token is rotated and cannot be used again.
+func (h *Handlers) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tokens, user, err := h.AuthService.Refresh(r.Context(), req.RefreshToken, h.clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to refresh token")
+		return
+	}
+
+	user.Password = ""
+	respondWithJSON(w, http.StatusOK, newAuthResponse(tokens, *user))
 }
 
-func generateToken(user models.User, secret string) (string, error) {
-	claims := &middleware.Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   string(user.Role),
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// Logout revokes the session belonging to the given refresh token, which also
+// invalidates every access token issued for it.
+func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.AuthService.Logout(r.Context(), req.RefreshToken); err != nil {
+		respondWithServiceError(w, err, "Failed to log out")
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// JWKS publishes the public keys that verify Timebook tokens so other
+// services can authenticate our users without being able to mint tokens
+func (h *Handlers) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, h.Keys.JWKS())
+}
+
+func newAuthResponse(tokens *services.TokenPair, user models.User) AuthResponse {
+	return AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		User:         user,
+		Roles:        user.Roles(),
+	}
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
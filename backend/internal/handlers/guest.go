@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/timebook/backend/internal/models"
+)
+
+// GuestBookingRequest is a booking made without an account
+type GuestBookingRequest struct {
+	BookingRequest
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+type ConfirmGuestAppointmentRequest struct {
+	Token string `json:"token"`
+}
+
+type CancelGuestAppointmentRequest struct {
+	Token  string `json:"token"`
+	Reason string `json:"reason"`
+}
+
+// GuestBookingResponse returns a guest's appointment with the token that
+// lets them view and cancel it. The token is left out while the booking
+// waits for the guest to confirm their email.
+type GuestBookingResponse struct {
+	Appointment *models.Appointment `json:"appointment"`
+	ManageToken string              `json:"manage_token,omitempty"`
+}
+
+// CreateGuestAppointment books a service for a client without an account.
+// The manage-booking token is emailed to the guest, and also returned unless
+// the booking needs the guest to confirm their email first.
+func (h *Handlers) CreateGuestAppointment(w http.ResponseWriter, r *http.Request) {
+	var req GuestBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	guest, err := h.GuestService.NewGuest(r.Context(), req.Name, req.Email, req.Phone, h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to create booking")
+		return
+	}
+
+	wait, err := h.GuestService.BookingWait(r.Context(), guest)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to create booking")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many bookings requested. Please try again later.")
+		return
+	}
+
+	appointment, ok := h.bookAppointment(w, r, req.BookingRequest, models.Appointment{Guest: guest})
+	if !ok {
+		return
+	}
+
+	token, err := h.GuestService.IssueManageToken(r.Context(), guest, appointment)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	if appointment.Status == models.StatusUnconfirmed {
+		token = ""
+	}
+	respondWithJSON(w, http.StatusCreated, GuestBookingResponse{
+		Appointment: appointment,
+		ManageToken: token,
+	})
+}
+
+// ConfirmGuestAppointment confirms the guest's email for the booking a
+// manage-booking token was issued for, sending it to the master
+func (h *Handlers) ConfirmGuestAppointment(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmGuestAppointmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	appointment, err := h.GuestService.ConfirmAppointment(r.Context(), req.Token)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to confirm booking")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, appointment)
+}
+
+// GetGuestAppointment shows the appointment a manage-booking token was
+// issued for
+func (h *Handlers) GetGuestAppointment(w http.ResponseWriter, r *http.Request) {
+	appointment, err := h.GuestService.GetAppointment(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to get appointment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, appointment)
+}
+
+// CancelGuestAppointment cancels the appointment a manage-booking token was
+// issued for
+func (h *Handlers) CancelGuestAppointment(w http.ResponseWriter, r *http.Request) {
+	var req CancelGuestAppointmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	appointment, err := h.GuestService.CancelAppointment(r.Context(), req.Token, req.Reason)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to cancel appointment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, appointment)
+}
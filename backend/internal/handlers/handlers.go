@@ -5,6 +5,10 @@ import (
 	"net/http"
 
 	"github.com/timebook/backend/internal/config"
+	"github.com/timebook/backend/internal/jwtkeys"
+	"github.com/timebook/backend/internal/notify"
+	"github.com/timebook/backend/internal/oidc"
+	"github.com/timebook/backend/internal/password"
 	"github.com/timebook/backend/internal/repositories"
 	"github.com/timebook/backend/internal/services"
 	"github.com/timebook/backend/internal/transaction"
@@ -12,13 +16,30 @@ import (
 )
 
 type Handlers struct {
-	DB                 *gorm.DB
-	Config             *config.Config
-	AppointmentService *services.AppointmentService
-	MasterService      *services.MasterService
+	DB                   *gorm.DB
+	Config               *config.Config
+	Keys                 *jwtkeys.KeySet
+	Passwords            *password.Policy
+	AppointmentService   *services.AppointmentService
+	AvailabilityService  *services.AvailabilityService
+	SlotHoldService      *services.SlotHoldService
+	MasterService        *services.MasterService
+	AuthService          *services.AuthService
+	InvitationService    *services.InvitationService
+	AccountService       *services.AccountService
+	LoginThrottleService *services.LoginThrottleService
+	MFAService           *services.MFAService
+	APIKeyService        *services.APIKeyService
+	OIDCService          *services.OIDCService
+	RoleService          *services.RoleService
+	ImpersonationService *services.ImpersonationService
+	PrivacyService       *services.PrivacyService
+	PhoneLoginService    *services.PhoneLoginService
+	GuestService         *services.GuestService
+	UserAdminService     *services.UserAdminService
 }
 
-func New(db *gorm.DB, cfg *config.Config) *Handlers {
+func New(db *gorm.DB, cfg *config.Config, keys *jwtkeys.KeySet, passwords *password.Policy, notifier notify.Notifier, sms notify.SMSSender, oidcProvider *oidc.Provider) *Handlers {
 	// Initialize transaction manager
 	txManager := transaction.New(db)
 
@@ -26,16 +47,69 @@ func New(db *gorm.DB, cfg *config.Config) *Handlers {
 	masterRepo := repositories.NewMasterRepository(db)
 	appointmentRepo := repositories.NewAppointmentRepository(db)
 	timeslotRepo := repositories.NewTimeslotRepository(db)
+	userRepo := repositories.NewUserRepository(db)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+	userTokenRepo := repositories.NewUserTokenRepository(db)
+	loginThrottleRepo := repositories.NewLoginThrottleRepository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	oidcStateRepo := repositories.NewOIDCStateRepository(db)
+	userIdentityRepo := repositories.NewUserIdentityRepository(db)
+	sessionRepo := repositories.NewSessionRepository(db)
+	roleGrantRepo := repositories.NewRoleGrantRepository(db)
+	impersonationRepo := repositories.NewImpersonationRepository(db)
+	serviceRepo := repositories.NewServiceRepository(db)
+	phoneLoginCodeRepo := repositories.NewPhoneLoginCodeRepository(db)
+	guestRepo := repositories.NewGuestRepository(db)
+	transitionRepo := repositories.NewAppointmentTransitionRepository(db)
+	slotHoldRepo := repositories.NewSlotHoldRepository(db)
 
 	// Initialize services
-	appointmentService := services.NewAppointmentService(appointmentRepo, timeslotRepo, txManager)
+	availabilityService := services.NewAvailabilityService(appointmentRepo, timeslotRepo, masterRepo, slotHoldRepo, cfg)
+	appointmentService := services.NewAppointmentService(appointmentRepo, timeslotRepo, masterRepo, serviceRepo, userRepo, transitionRepo, slotHoldRepo, availabilityService, txManager, cfg)
+	slotHoldService := services.NewSlotHoldService(slotHoldRepo, serviceRepo, masterRepo, userRepo, availabilityService, txManager, cfg)
 	masterService := services.NewMasterService(masterRepo, txManager)
+	authService := services.NewAuthService(userRepo, refreshTokenRepo, sessionRepo, roleGrantRepo, impersonationRepo, txManager, keys, cfg)
+	invitationService := services.NewInvitationService(invitationRepo, userRepo, masterRepo, passwords, txManager, keys, cfg)
+	accountService := services.NewAccountService(userRepo, userTokenRepo, refreshTokenRepo, sessionRepo, guestRepo, auditRepo, passwords, notifier, txManager, cfg)
+	loginThrottleService := services.NewLoginThrottleService(loginThrottleRepo, auditRepo, userRepo, txManager, cfg)
+	mfaService := services.NewMFAService(userRepo, recoveryCodeRepo, loginThrottleRepo, txManager, keys, cfg)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo)
+	oidcService := services.NewOIDCService(oidcProvider, oidcStateRepo, userIdentityRepo, userRepo, guestRepo, passwords, txManager, cfg)
+	roleService := services.NewRoleService(roleGrantRepo, userRepo, masterRepo, auditRepo, txManager)
+	impersonationService := services.NewImpersonationService(impersonationRepo, userRepo, auditRepo, txManager, keys, cfg)
+	phoneLoginService := services.NewPhoneLoginService(phoneLoginCodeRepo, userRepo, sms, txManager, cfg)
+	userAdminService := services.NewUserAdminService(userRepo, masterRepo, sessionRepo, refreshTokenRepo, auditRepo, txManager)
+	guestService := services.NewGuestService(guestRepo, userRepo, appointmentRepo, timeslotRepo, transitionRepo, masterRepo, availabilityService, notifier, txManager, keys, cfg)
+	privacyService := services.NewPrivacyService(
+		userRepo, appointmentRepo, masterRepo, serviceRepo, timeslotRepo, transitionRepo, sessionRepo, refreshTokenRepo,
+		userTokenRepo, userIdentityRepo, apiKeyRepo, recoveryCodeRepo, roleGrantRepo, guestRepo, auditRepo, passwords, txManager, cfg,
+	)
 
 	return &Handlers{
-		DB:                 db,
-		Config:             cfg,
-		AppointmentService: appointmentService,
-		MasterService:      masterService,
+		DB:                   db,
+		Config:               cfg,
+		Keys:                 keys,
+		Passwords:            passwords,
+		AppointmentService:   appointmentService,
+		AvailabilityService:  availabilityService,
+		SlotHoldService:      slotHoldService,
+		MasterService:        masterService,
+		AuthService:          authService,
+		InvitationService:    invitationService,
+		AccountService:       accountService,
+		LoginThrottleService: loginThrottleService,
+		MFAService:           mfaService,
+		APIKeyService:        apiKeyService,
+		OIDCService:          oidcService,
+		RoleService:          roleService,
+		ImpersonationService: impersonationService,
+		PrivacyService:       privacyService,
+		PhoneLoginService:    phoneLoginService,
+		GuestService:         guestService,
+		UserAdminService:     userAdminService,
 	}
 }
 
@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"errors"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/services"
 )
 
 // getContextUserID returns the authenticated user's ID from context.
@@ -24,6 +28,49 @@ func getContextUserID(w http.ResponseWriter, r *http.Request) (uint, bool) {
 	return userID, true
 }
 
+// getContextSessionID returns the session ID of the access token used for
+// the request, or "" for requests authenticated another way
+func getContextSessionID(r *http.Request) string {
+	sessionID, _ := r.Context().Value("session_id").(string)
+	return sessionID
+}
+
+// respondWithServiceError writes an error returned by the service layer.
+// Application errors keep their status and message; anything else is
+// reported as a 500 with the given fallback message.
+func respondWithServiceError(w http.ResponseWriter, err error, fallback string) {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		respondWithError(w, appErr.Status, appErr.Message)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, fallback)
+}
+
+// clientIP returns the address of the client making the request. The
+// X-Real-IP header set by our nginx proxy is only honoured when
+// TRUST_PROXY_HEADERS is enabled, since clients can forge it otherwise.
+func (h *Handlers) clientIP(r *http.Request) string {
+	if h.Config.TrustProxyHeaders {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientInfo describes the requesting device for session tracking
+func (h *Handlers) clientInfo(r *http.Request) services.ClientInfo {
+	return services.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: h.clientIP(r),
+	}
+}
+
 // getPathValue extracts a path parameter from the URL
 // For Go 1.21 compatibility (PathValue is only in Go 1.22+)
 // Extracts ID from patterns like /api/services/{id}/slots
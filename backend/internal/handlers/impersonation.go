@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/timebook/backend/internal/middleware"
+	"github.com/timebook/backend/internal/models"
+)
+
+type StartImpersonationRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ImpersonationResponse struct {
+	ImpersonationID uint        `json:"impersonation_id"`
+	Token           string      `json:"token"`
+	ExpiresAt       time.Time   `json:"expires_at"`
+	Banner          string      `json:"banner"`
+	User            models.User `json:"user"`
+}
+
+// StartImpersonation issues a short-lived token that lets the admin see the
+// app as the given user. Admin accounts cannot be impersonated.
+func (h *Handlers) StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req StartImpersonationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	token, err := h.ImpersonationService.Start(r.Context(), adminID, getContextSessionID(r), userID, req.Reason, h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to start impersonation")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, ImpersonationResponse{
+		ImpersonationID: token.Impersonation.ID,
+		Token:           token.AccessToken,
+		ExpiresAt:       token.Impersonation.ExpiresAt,
+		Banner:          token.Banner,
+		User:            *token.User,
+	})
+}
+
+// EndImpersonation invalidates an impersonation token the admin was given
+func (h *Handlers) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	impersonationID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid impersonation ID")
+		return
+	}
+
+	if err := h.ImpersonationService.End(r.Context(), adminID, impersonationID, h.clientIP(r)); err != nil {
+		respondWithServiceError(w, err, "Failed to end impersonation")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Impersonation ended"})
+}
+
+// AuditImpersonatedRequest implements middleware.ImpersonationAuditor
+func (h *Handlers) AuditImpersonatedRequest(r *http.Request, claims *middleware.Claims) error {
+	path := r.URL.Path
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	return h.ImpersonationService.RecordRequest(r.Context(), claims, r.Method, path, h.clientIP(r))
+}
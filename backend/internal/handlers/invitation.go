@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/services"
+)
+
+type CreateInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type InvitationResponse struct {
+	Invitation *models.Invitation `json:"invitation"`
+	Token      string             `json:"token"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+}
+
+// CreateInvitation lets an admin invite someone with a given role.
+// The invite token is returned once and must be delivered to the invitee.
+func (h *Handlers) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	invitation, token, err := h.InvitationService.CreateInvitation(r.Context(), adminID, req.Email, models.UserRole(req.Role))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to create invitation")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, InvitationResponse{
+		Invitation: invitation,
+		Token:      token,
+	})
+}
+
+// GetInvitations lists invitations that have not been accepted, revoked or expired
+func (h *Handlers) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.InvitationService.ListPending(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch invitations")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, invitations)
+}
+
+// RevokeInvitation cancels a pending invitation
+func (h *Handlers) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	invitationID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	if err := h.InvitationService.RevokeInvitation(r.Context(), invitationID); err != nil {
+		respondWithServiceError(w, err, "Failed to revoke invitation")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation creates the invited account and logs it in
+func (h *Handlers) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.InvitationService.AcceptInvitation(r.Context(), services.AcceptInvitationInput{
+		Token:    req.Token,
+		Password: req.Password,
+		Name:     req.Name,
+		Phone:    req.Phone,
+	})
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to accept invitation")
+		return
+	}
+
+	if err := h.AccountService.SendVerification(r.Context(), user); err != nil {
+		log.Printf("accept invitation: failed to send verification to user %d: %v", user.ID, err)
+	}
+
+	tokens, err := h.AuthService.IssueTokens(r.Context(), user, h.clientInfo(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	user.Password = ""
+	respondWithJSON(w, http.StatusCreated, newAuthResponse(tokens, *user))
+}
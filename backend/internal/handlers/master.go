@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/services"
 )
 
 func (h *Handlers) GetMasterProfile(w http.ResponseWriter, r *http.Request) {
@@ -22,6 +25,47 @@ func (h *Handlers) GetMasterProfile(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, user)
 }
 
+type UpdateMasterProfileRequest struct {
+	Bio                     *string `json:"bio"`
+	Specialty               *string `json:"specialty"`
+	Experience              *int    `json:"experience"`
+	CancellationNoticeHours *int    `json:"cancellation_notice_hours"`
+	TimeZone                *string `json:"time_zone"`
+	WorkdayStartHour        *int    `json:"workday_start_hour"`
+	WorkdayEndHour          *int    `json:"workday_end_hour"`
+}
+
+// UpdateMasterProfile changes the fields of the master's public profile,
+// cancellation policy and working hours that are present in the request
+func (h *Handlers) UpdateMasterProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateMasterProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	profile, err := h.MasterService.UpdateProfile(r.Context(), userID, services.MasterProfileUpdate{
+		Bio:                     req.Bio,
+		Specialty:               req.Specialty,
+		Experience:              req.Experience,
+		CancellationNoticeHours: req.CancellationNoticeHours,
+		TimeZone:                req.TimeZone,
+		WorkdayStartHour:        req.WorkdayStartHour,
+		WorkdayEndHour:          req.WorkdayEndHour,
+	})
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to update master profile")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, profile)
+}
+
 func (h *Handlers) CreateService(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(uint)
 
@@ -70,11 +114,11 @@ func (h *Handlers) GetMasterServices(w http.ResponseWriter, r *http.Request) {
 	if err := h.DB.Where("user_id = ?", userID).First(&masterProfile).Error; err != nil {
 		// Lazy-create master profile for users with role master (e.g. legacy accounts)
 		var user models.User
-		if err := h.DB.First(&user, userID).Error; err != nil {
+		if err := h.DB.Preload("RoleGrants").First(&user, userID).Error; err != nil {
 			respondWithError(w, http.StatusNotFound, "User not found")
 			return
 		}
-		if user.Role != models.RoleMaster {
+		if !user.HasRole(models.RoleMaster) {
 			respondWithError(w, http.StatusNotFound, "Master profile not found")
 			return
 		}
@@ -347,11 +391,11 @@ func (h *Handlers) GetMasterAppointments(w http.ResponseWriter, r *http.Request)
 	if err := h.DB.Where("user_id = ?", userID).First(&masterProfile).Error; err != nil {
 		// Lazy-create master profile for users with role master (e.g. legacy accounts)
 		var user models.User
-		if err := h.DB.First(&user, userID).Error; err != nil {
+		if err := h.DB.Preload("RoleGrants").First(&user, userID).Error; err != nil {
 			respondWithError(w, http.StatusNotFound, "User not found")
 			return
 		}
-		if user.Role != models.RoleMaster {
+		if !user.HasRole(models.RoleMaster) {
 			respondWithError(w, http.StatusNotFound, "Master profile not found")
 			return
 		}
@@ -362,13 +406,72 @@ func (h *Handlers) GetMasterAppointments(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	// Guest bookings waiting for email confirmation are not the master's concern yet
 	var appointments []models.Appointment
-	if err := h.DB.Preload("User").Preload("Service").Preload("ServiceOption").Where("master_id = ?", masterProfile.ID).Find(&appointments).Error; err != nil {
+	if err := h.DB.Preload("User").Preload("Guest").Preload("Service").Preload("ServiceOption").
+		Where("master_id = ? AND status <> ?", masterProfile.ID, models.StatusUnconfirmed).Find(&appointments).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch appointments")
+		return
+	}
+
+	noShows, err := h.AppointmentService.ClientNoShows(r.Context(), masterProfile.ID)
+	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to fetch appointments")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, appointments)
+	response := make([]MasterAppointment, len(appointments))
+	for i := range appointments {
+		response[i] = MasterAppointment{
+			Appointment:   &appointments[i],
+			ClientNoShows: noShows.For(&appointments[i]),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// MasterAppointment is an appointment as shown to its master, with how many
+// times the client has not turned up to the master's appointments
+type MasterAppointment struct {
+	*models.Appointment
+	ClientNoShows int64 `json:"client_no_shows"`
+}
+
+// CompleteAppointment records that the client turned up to an appointment
+func (h *Handlers) CompleteAppointment(w http.ResponseWriter, r *http.Request) {
+	h.markAttendance(w, r, models.StatusCompleted)
+}
+
+// MarkAppointmentNoShow records that the client did not turn up to an appointment
+func (h *Handlers) MarkAppointmentNoShow(w http.ResponseWriter, r *http.Request) {
+	h.markAttendance(w, r, models.StatusNoShow)
+}
+
+func (h *Handlers) markAttendance(w http.ResponseWriter, r *http.Request, status models.AppointmentStatus) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	appointmentID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	reason, ok := decodeStatusReason(w, r)
+	if !ok {
+		return
+	}
+
+	appointment, err := h.AppointmentService.MarkAttendance(r.Context(), userID, appointmentID, status, reason)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to record attendance")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, appointment)
 }
 
 func (h *Handlers) ConfirmAppointment(w http.ResponseWriter, r *http.Request) {
@@ -394,15 +497,20 @@ func (h *Handlers) ConfirmAppointment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use service layer to confirm appointment with transaction
-	confirmedAppointment, err := h.AppointmentService.ConfirmAppointment(r.Context(), appointmentID)
+	confirmedAppointment, err := h.AppointmentService.ConfirmAppointment(r.Context(), userID, appointmentID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to confirm appointment")
+		respondWithServiceError(w, err, "Failed to confirm appointment")
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, confirmedAppointment)
 }
 
+// StatusReasonRequest optionally explains why an appointment's status was changed
+type StatusReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
 func (h *Handlers) RejectAppointment(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(uint)
 	appointmentID, err := getIDParam(r)
@@ -425,16 +533,62 @@ func (h *Handlers) RejectAppointment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	reason, ok := decodeStatusReason(w, r)
+	if !ok {
+		return
+	}
+
 	// Use service layer to reject appointment with transaction
-	rejectedAppointment, err := h.AppointmentService.RejectAppointment(r.Context(), appointmentID)
+	rejectedAppointment, err := h.AppointmentService.RejectAppointment(r.Context(), userID, appointmentID, reason)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to reject appointment")
+		respondWithServiceError(w, err, "Failed to reject appointment")
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, rejectedAppointment)
 }
 
+// decodeStatusReason reads the optional reason for a status change from the
+// request body. It writes the error response itself and reports whether decoding
+// succeeded.
+func decodeStatusReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// The reason is optional, so an empty body is fine
+	var req StatusReasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return "", false
+	}
+	return req.Reason, true
+}
+
+// RescheduleMasterAppointment moves an upcoming appointment booked with the
+// master to a new time, keeping its status
+func (h *Handlers) RescheduleMasterAppointment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	appointmentID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	startTime, ok := decodeRescheduleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	appointment, err := h.AppointmentService.RescheduleByMaster(r.Context(), userID, appointmentID, startTime)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to reschedule appointment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, appointment)
+}
+
 // CreateAppointmentForClient allows a master to create an appointment on behalf of a client.
 // Used for the "Work" flow when booking from the master calendar.
 func (h *Handlers) CreateAppointmentForClient(w http.ResponseWriter, r *http.Request) {
@@ -443,12 +597,6 @@ func (h *Handlers) CreateAppointmentForClient(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	var masterProfile models.MasterProfile
-	if err := h.DB.Where("user_id = ?", masterUserID).First(&masterProfile).Error; err != nil {
-		respondWithError(w, http.StatusNotFound, "Master profile not found")
-		return
-	}
-
 	var req struct {
 		UserID          uint   `json:"user_id"`
 		ServiceID       uint   `json:"service_id"`
@@ -462,89 +610,22 @@ func (h *Handlers) CreateAppointmentForClient(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Verify service belongs to this master
-	var service models.Service
-	if err := h.DB.Preload("Master").Preload("Options").Where("id = ? AND master_id = ?", req.ServiceID, masterProfile.ID).First(&service).Error; err != nil {
-		respondWithError(w, http.StatusNotFound, "Service not found")
-		return
-	}
-
-	// Verify client user exists and has role "user"
-	var client models.User
-	if err := h.DB.Where("id = ? AND role = ?", req.UserID, models.RoleUser).First(&client).Error; err != nil {
-		respondWithError(w, http.StatusNotFound, "Client not found")
-		return
-	}
-
-	duration := service.Duration
-	if len(service.Options) > 0 {
-		if req.ServiceOptionID == nil {
-			respondWithError(w, http.StatusBadRequest, "This service has sub-categories. Please select one.")
-			return
-		}
-		var option models.ServiceOption
-		if err := h.DB.Where("id = ? AND service_id = ?", *req.ServiceOptionID, req.ServiceID).First(&option).Error; err != nil {
-			respondWithError(w, http.StatusNotFound, "Service sub-category not found")
-			return
-		}
-		duration = option.Duration
-	}
-
 	startTime, err := parseTime(req.StartTime)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid time format")
 		return
 	}
 
-	endTime := startTime.Add(minutesToDuration(duration))
-
-	// Check for conflicts (master-wide: any overlapping appointment blocks the slot)
-	var conflictingAppointment models.Appointment
-	if err := h.DB.Where(
-		"master_id = ? AND deleted_at IS NULL AND status IN (?, ?) AND start_time < ? AND end_time > ?",
-		masterProfile.ID,
-		models.StatusPending,
-		models.StatusConfirmed,
-		endTime, startTime,
-	).First(&conflictingAppointment).Error; err == nil {
-		respondWithError(w, http.StatusConflict, "Time slot conflicts with existing appointment")
-		return
-	}
-
-	// Mark matching time slot as booked if it exists
-	var timeSlot models.TimeSlot
-	if err := h.DB.Where(
-		"master_id = ? AND service_id = ? AND start_time = ? AND end_time = ? AND is_booked = ? AND deleted_at IS NULL",
-		masterProfile.ID,
-		req.ServiceID,
-		startTime,
-		endTime,
-		false,
-	).First(&timeSlot).Error; err == nil {
-		timeSlot.IsBooked = true
-		h.DB.Save(&timeSlot)
-	}
-
-	appointment := models.Appointment{
-		UserID:          req.UserID,
-		MasterID:        masterProfile.ID,
+	appointment, err := h.AppointmentService.BookForClient(r.Context(), masterUserID, req.UserID, services.Booking{
 		ServiceID:       req.ServiceID,
 		ServiceOptionID: req.ServiceOptionID,
 		StartTime:       startTime,
-		EndTime:         endTime,
-		Status:          models.StatusConfirmed, // Master-created appointments are auto-confirmed
 		Notes:           req.Notes,
-	}
-
-	if err := h.DB.Create(&appointment).Error; err != nil {
-		if timeSlot.ID != 0 {
-			timeSlot.IsBooked = false
-			h.DB.Save(&timeSlot)
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to create appointment")
+	})
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to create appointment")
 		return
 	}
 
-	h.DB.Preload("Service").Preload("Master").Preload("User").Preload("ServiceOption").First(&appointment, appointment.ID)
 	respondWithJSON(w, http.StatusCreated, appointment)
 }
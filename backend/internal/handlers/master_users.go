@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
 )
 
 // SearchUsers allows masters to search for clients by name or email.
@@ -24,7 +25,8 @@ func (h *Handlers) SearchUsers(w http.ResponseWriter, r *http.Request) {
 	searchPattern := "%" + q + "%"
 	var users []models.User
 	if err := h.DB.Select("id", "name", "email", "phone").
-		Where("role = ? AND deleted_at IS NULL", models.RoleUser).
+		Scopes(repositories.UserHasRole(models.RoleUser)).
+		Where("anonymized_at IS NULL").
 		Where("name ILIKE ? OR email ILIKE ?", searchPattern, searchPattern).
 		Limit(20).
 		Find(&users).Error; err != nil {
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type LoginTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollTwoFactor starts TOTP enrollment and returns the secret and otpauth URI
+func (h *Handlers) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.MFAService.Enroll(r.Context(), userID)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to start two-factor enrollment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, enrollment)
+}
+
+// ActivateTwoFactor confirms enrollment with a TOTP code and returns recovery codes
+func (h *Handlers) ActivateTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	codes, err := h.MFAService.Activate(r.Context(), userID, req.Code)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off
+func (h *Handlers) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.MFAService.Disable(r.Context(), userID, req.Code); err != nil {
+		respondWithServiceError(w, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (h *Handlers) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	codes, err := h.MFAService.RegenerateRecoveryCodes(r.Context(), userID, req.Code)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// LoginTwoFactor completes a login that returned an MFA challenge
+func (h *Handlers) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req LoginTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	challenged, err := h.MFAService.ChallengeUser(r.Context(), req.MFAToken)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to log in")
+		return
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	ip := h.clientIP(r)
+	wait, err := h.LoginThrottleService.Check(r.Context(), challenged.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts. Please try again later.")
+		return
+	}
+
+	user, err := h.MFAService.CompleteChallenge(r.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		h.recordLoginFailure(r, challenged.Email, ip)
+		respondWithServiceError(w, err, "Failed to log in")
+		return
+	}
+
+	if err := h.LoginThrottleService.RecordSuccess(r.Context(), challenged.Email); err != nil {
+		log.Printf("login: failed to reset attempts for user %d: %v", user.ID, err)
+	}
+
+	tokens, err := h.AuthService.IssueTokens(r.Context(), user, h.clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to generate token")
+		return
+	}
+
+	user.Password = ""
+	respondWithJSON(w, http.StatusOK, newAuthResponse(tokens, *user))
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type OIDCCallbackRequest struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+// StartOIDCLogin returns the identity provider URL the browser should be
+// sent to. The provider redirects back to OIDC_REDIRECT_URL with a code and
+// state, which the frontend posts to CompleteOIDCLogin.
+func (h *Handlers) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, err := h.OIDCService.StartLogin(r.Context())
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to start sign-on")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, OIDCLoginResponse{AuthorizationURL: authURL})
+}
+
+// CompleteOIDCLogin redeems the provider's authorization code and logs the
+// linked user in
+func (h *Handlers) CompleteOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var req OIDCCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.OIDCService.CompleteLogin(r.Context(), req.State, req.Code)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to complete sign-on")
+		return
+	}
+
+	h.respondWithLogin(w, r, user)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+type PhoneCodeRequest struct {
+	Phone string `json:"phone"`
+}
+
+type PhoneVerifyRequest struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
+
+// RequestPhoneCode texts a one-time login code to the phone number. The
+// response is the same whether or not the number belongs to an account.
+func (h *Handlers) RequestPhoneCode(w http.ResponseWriter, r *http.Request) {
+	var req PhoneCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	wait, err := h.PhoneLoginService.RequestCode(r.Context(), req.Phone, h.clientIP(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to send code")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many codes requested. Please try again later.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If an account uses this number, a login code has been sent",
+	})
+}
+
+// VerifyPhoneCode logs in the account with the phone number if the code is
+// correct. Accounts with two-factor authentication still get a challenge.
+func (h *Handlers) VerifyPhoneCode(w http.ResponseWriter, r *http.Request) {
+	var req PhoneVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.PhoneLoginService.VerifyCode(r.Context(), req.Phone, req.Code)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to verify code")
+		return
+	}
+
+	h.respondWithLogin(w, r, user)
+}
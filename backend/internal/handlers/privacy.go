@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// ExportUserData returns everything stored about the current user as a
+// downloadable JSON archive
+func (h *Handlers) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := h.PrivacyService.ExportData(r.Context(), userID)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to export account data")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="timebook-export.json"`)
+	respondWithJSON(w, http.StatusOK, export)
+}
+
+// DeleteAccount anonymizes the current user's account after they confirm
+// their password, or sign in again if they have none. Their appointments are
+// kept without personal data.
+func (h *Handlers) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Accounts without a password may send an empty body
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.PrivacyService.DeleteAccount(r.Context(), userID, req.Password, getContextSessionID(r), h.clientIP(r)); err != nil {
+		respondWithServiceError(w, err, "Failed to delete account")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Account deleted"})
+}
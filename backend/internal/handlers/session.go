@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/timebook/backend/internal/models"
+)
+
+type SessionResponse struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions lists the devices the current user is signed in on
+func (h *Handlers) GetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.AuthService.ListSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+
+	currentSessionID := getContextSessionID(r)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			Session: session,
+			Current: session.SessionID == currentSessionID,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// RevokeSession signs the current user out of one session, e.g. a lost device
+func (h *Handlers) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.AuthService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		respondWithServiceError(w, err, "Failed to revoke session")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs the current user out everywhere except the
+// session making the request
+func (h *Handlers) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.AuthService.RevokeOtherSessions(r.Context(), userID, getContextSessionID(r))
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to revoke sessions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
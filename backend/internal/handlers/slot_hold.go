@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/timebook/backend/internal/services"
+)
+
+// SlotHoldRequest asks to hold a time for a service while the client
+// finishes booking
+type SlotHoldRequest struct {
+	ServiceID       uint   `json:"service_id"`
+	ServiceOptionID *uint  `json:"service_option_id,omitempty"`
+	StartTime       string `json:"start_time"`
+}
+
+// CreateSlotHold reserves a time for the user for a short while. Booking
+// with the returned hold ID turns it into an appointment.
+func (h *Handlers) CreateSlotHold(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req SlotHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	startTime, err := parseTime(req.StartTime)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid time format")
+		return
+	}
+
+	hold, err := h.SlotHoldService.Create(r.Context(), userID, services.Booking{
+		ServiceID:       req.ServiceID,
+		ServiceOptionID: req.ServiceOptionID,
+		StartTime:       startTime,
+	})
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to hold time slot")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, hold)
+}
+
+// ReleaseSlotHold gives up one of the user's holds so others can book the time
+func (h *Handlers) ReleaseSlotHold(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	holdID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid hold ID")
+		return
+	}
+
+	if err := h.SlotHoldService.Release(r.Context(), userID, holdID); err != nil {
+		respondWithServiceError(w, err, "Failed to release hold")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Hold released"})
+}
@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/services"
 )
 
 func (h *Handlers) GetUserProfile(w http.ResponseWriter, r *http.Request) {
@@ -21,6 +26,62 @@ func (h *Handlers) GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, user)
 }
 
+// UpdateProfileRequest changes the fields that are present. A new email
+// needs the current password and takes effect once confirmed.
+type UpdateProfileRequest struct {
+	Name            *string `json:"name"`
+	Phone           *string `json:"phone"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// UpdateUserProfile changes the current user's name, phone number or email.
+// Wrong passwords given for an email change count towards the login lockout.
+func (h *Handlers) UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	email, _ := r.Context().Value("user_email").(string)
+	ip := h.clientIP(r)
+	if req.Email != nil {
+		wait, err := h.LoginThrottleService.Check(r.Context(), email, ip)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to update profile")
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondWithError(w, http.StatusTooManyRequests, "Too many failed attempts. Please try again later.")
+			return
+		}
+	}
+
+	user, err := h.AccountService.UpdateProfile(r.Context(), userID, services.ProfileUpdate{
+		Name:            req.Name,
+		Phone:           req.Phone,
+		Email:           req.Email,
+		CurrentPassword: req.CurrentPassword,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrIncorrectPassword) {
+			h.recordLoginFailure(r, email, ip)
+		}
+		respondWithServiceError(w, err, "Failed to update profile")
+		return
+	}
+
+	user.Password = ""
+	respondWithJSON(w, http.StatusOK, user)
+}
+
 func (h *Handlers) GetServices(w http.ResponseWriter, r *http.Request) {
 	var services []models.Service
 	query := h.DB.Preload("Master").Preload("Master.User").Preload("Options")
@@ -43,6 +104,9 @@ func (h *Handlers) GetServices(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, services)
 }
 
+// GetAvailableSlots lists the hourly start times in the master's working
+// hours for a service, marking those that cannot be booked. The optional
+// service_option_id sets the duration for services with sub-categories.
 func (h *Handlers) GetAvailableSlots(w http.ResponseWriter, r *http.Request) {
 	serviceID, err := getIDParam(r)
 	if err != nil {
@@ -51,11 +115,32 @@ func (h *Handlers) GetAvailableSlots(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var service models.Service
-	if err := h.DB.First(&service, serviceID).Error; err != nil {
+	if err := h.DB.Preload("Options").First(&service, serviceID).Error; err != nil {
 		respondWithError(w, http.StatusNotFound, "Service not found")
 		return
 	}
 
+	duration := service.Duration
+	if s := r.URL.Query().Get("service_option_id"); s != "" {
+		optionID, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid service option ID")
+			return
+		}
+		found := false
+		for _, option := range service.Options {
+			if option.ID == uint(optionID) {
+				duration = option.Duration
+				found = true
+				break
+			}
+		}
+		if !found {
+			respondWithError(w, http.StatusNotFound, "Service sub-category not found")
+			return
+		}
+	}
+
 	now := time.Now()
 	startDate := now
 	endDate := now.AddDate(0, 0, 1)
@@ -71,62 +156,22 @@ func (h *Handlers) GetAvailableSlots(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Fetch ALL booked DB time slots for this master (unified calendar —
-	// a slot blocked on any service blocks the hour for every service).
-	var bookedDbSlots []models.TimeSlot
-	h.DB.Where(
-		"master_id = ? AND deleted_at IS NULL AND is_booked = ? AND start_time < ? AND end_time > ?",
-		service.MasterID, true, endDate, startDate,
-	).Find(&bookedDbSlots)
-
-	// Fetch pending/confirmed appointments for this master (all services).
-	var appointments []models.Appointment
-	h.DB.Where(
-		"master_id = ? AND deleted_at IS NULL AND status IN (?, ?) AND start_time < ? AND end_time > ?",
-		service.MasterID, models.StatusPending, models.StatusConfirmed, endDate, startDate,
-	).Find(&appointments)
-
-	// Generate 1-hour working-hour slots (8 AM – 10 PM in the client's
-	// local timezone). The frontend sends startDate as local-midnight
-	// expressed in UTC, so startDate + 8 h = 8 AM local.
-	workStart := startDate.Add(8 * time.Hour)
+	available, err := h.AvailabilityService.Slots(r.Context(), service.MasterID, minutesToDuration(duration), startDate, endDate)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to fetch slots")
+		return
+	}
 
 	slots := []map[string]interface{}{}
-	for hour := 0; hour < 14; hour++ {
-		slotStart := workStart.Add(time.Duration(hour) * time.Hour)
-		slotEnd := slotStart.Add(time.Hour)
-
-		// Mark past slots as unavailable; always return them so the grid is complete.
-		isPast := slotEnd.Before(now)
-
-		isBooked := false
-
-		// Check master-wide booked DB slots (manually blocked by master).
-		for _, dbSlot := range bookedDbSlots {
-			if dbSlot.StartTime.Before(slotEnd) && dbSlot.EndTime.After(slotStart) {
-				isBooked = true
-				break
-			}
-		}
-
-		// Check appointments (pending or confirmed on any service).
-		if !isBooked {
-			for _, apt := range appointments {
-				if apt.StartTime.Before(slotEnd) && apt.EndTime.After(slotStart) {
-					isBooked = true
-					break
-				}
-			}
-		}
-
+	for _, slot := range available {
 		slots = append(slots, map[string]interface{}{
 			"id":         0,
 			"service_id": serviceID,
-			"start_time": slotStart.Format(time.RFC3339),
-			"end_time":   slotEnd.Format(time.RFC3339),
-			"available":  !isBooked && !isPast,
-			"is_booked":  isBooked,
-			"is_past":    isPast,
+			"start_time": slot.StartTime.Format(time.RFC3339),
+			"end_time":   slot.EndTime.Format(time.RFC3339),
+			"available":  slot.Available(),
+			"is_booked":  slot.IsBooked,
+			"is_past":    slot.IsPast,
 		})
 	}
 
@@ -136,110 +181,137 @@ func (h *Handlers) GetAvailableSlots(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(uint)
 
-	var req struct {
-		ServiceID       uint   `json:"service_id"`
-		ServiceOptionID *uint  `json:"service_option_id,omitempty"`
-		StartTime       string `json:"start_time"`
-		Notes           string `json:"notes"`
+	var req BookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	appointment, ok := h.bookAppointment(w, r, req, models.Appointment{UserID: &userID})
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, appointment)
+}
+
+type CancelAppointmentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CancelAppointment cancels one of the user's own upcoming appointments,
+// subject to the master's cancellation policy, and frees its time slot
+func (h *Handlers) CancelAppointment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	appointmentID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	// The reason is optional, so an empty body is fine
+	var req CancelAppointmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
 		respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	// Get service
-	var service models.Service
-	if err := h.DB.Preload("Master").Preload("Options").First(&service, req.ServiceID).Error; err != nil {
-		respondWithError(w, http.StatusNotFound, "Service not found")
+	appointment, err := h.AppointmentService.CancelAppointment(r.Context(), userID, appointmentID, req.Reason)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to cancel appointment")
 		return
 	}
 
-	// Determine the duration to use
-	duration := service.Duration
+	respondWithJSON(w, http.StatusOK, appointment)
+}
 
-	// If the service has sub-categories (options), a selection is required
-	if len(service.Options) > 0 {
-		if req.ServiceOptionID == nil {
-			respondWithError(w, http.StatusBadRequest, "This service has sub-categories. Please select one.")
-			return
-		}
-		// Verify the option belongs to this service
-		var option models.ServiceOption
-		if err := h.DB.Where("id = ? AND service_id = ?", *req.ServiceOptionID, req.ServiceID).First(&option).Error; err != nil {
-			respondWithError(w, http.StatusNotFound, "Service sub-category not found")
-			return
-		}
-		duration = option.Duration
+// RescheduleAppointmentRequest moves an appointment to a new start time
+type RescheduleAppointmentRequest struct {
+	StartTime string `json:"start_time"`
+}
+
+// RescheduleAppointment moves one of the user's own upcoming appointments to
+// a new time. A confirmed appointment needs the master's approval again.
+func (h *Handlers) RescheduleAppointment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getContextUserID(w, r)
+	if !ok {
+		return
+	}
+
+	appointmentID, err := getIDParam(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	startTime, ok := decodeRescheduleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	appointment, err := h.AppointmentService.RescheduleByClient(r.Context(), userID, appointmentID, startTime)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to reschedule appointment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, appointment)
+}
+
+// decodeRescheduleRequest reads the new start time from the request body. It
+// writes the error response itself and reports whether decoding succeeded.
+func decodeRescheduleRequest(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	var req RescheduleAppointmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return time.Time{}, false
 	}
 
-	// Parse start time
 	startTime, err := parseTime(req.StartTime)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid time format")
-		return
+		return time.Time{}, false
 	}
+	return startTime, true
+}
 
-	// Calculate end time using the resolved duration
-	endTime := startTime.Add(minutesToDuration(duration))
-
-	// Check for conflicting appointments (pending or confirmed)
-	var conflictingAppointment models.Appointment
-	if err := h.DB.Where(
-		"master_id = ? AND service_id = ? AND deleted_at IS NULL AND status IN (?, ?) AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?) OR (start_time >= ? AND end_time <= ?))",
-		service.MasterID,
-		req.ServiceID,
-		models.StatusPending,
-		models.StatusConfirmed,
-		startTime, startTime,
-		endTime, endTime,
-		startTime, endTime,
-	).First(&conflictingAppointment).Error; err == nil {
-		respondWithError(w, http.StatusConflict, "Time slot conflicts with existing appointment")
-		return
-	}
-
-	// Check if there's a matching time slot and mark it as booked
-	var timeSlot models.TimeSlot
-	if err := h.DB.Where(
-		"master_id = ? AND service_id = ? AND start_time = ? AND end_time = ? AND is_booked = ? AND deleted_at IS NULL",
-		service.MasterID,
-		req.ServiceID,
-		startTime,
-		endTime,
-		false,
-	).First(&timeSlot).Error; err == nil {
-		// Found matching time slot, mark it as booked
-		timeSlot.IsBooked = true
-		if err := h.DB.Save(&timeSlot).Error; err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Failed to book time slot")
-			return
-		}
+// BookingRequest is a client's request to book a service
+type BookingRequest struct {
+	ServiceID       uint   `json:"service_id"`
+	ServiceOptionID *uint  `json:"service_option_id,omitempty"`
+	StartTime       string `json:"start_time"`
+	Notes           string `json:"notes"`
+
+	// HoldID books a time the client is holding
+	HoldID *uint `json:"hold_id,omitempty"`
+}
+
+// bookAppointment creates a pending appointment for the client set on
+// appointment, marking the matching time slot as booked. It writes the error
+// response itself and reports whether the booking succeeded.
+func (h *Handlers) bookAppointment(w http.ResponseWriter, r *http.Request, req BookingRequest, appointment models.Appointment) (*models.Appointment, bool) {
+	startTime, err := parseTime(req.StartTime)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid time format")
+		return nil, false
 	}
 
-	appointment := models.Appointment{
-		UserID:          userID,
-		MasterID:        service.MasterID,
+	booked, err := h.AppointmentService.Book(r.Context(), services.Booking{
 		ServiceID:       req.ServiceID,
 		ServiceOptionID: req.ServiceOptionID,
 		StartTime:       startTime,
-		EndTime:         endTime,
-		Status:          models.StatusPending,
 		Notes:           req.Notes,
+		HoldID:          req.HoldID,
+	}, appointment)
+	if err != nil {
+		respondWithServiceError(w, err, "Failed to create appointment")
+		return nil, false
 	}
-
-	if err := h.DB.Create(&appointment).Error; err != nil {
-		// If appointment creation fails, unbook the slot if we booked it
-		if timeSlot.ID != 0 {
-			timeSlot.IsBooked = false
-			h.DB.Save(&timeSlot)
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to create appointment")
-		return
-	}
-
-	h.DB.Preload("Service").Preload("Master").Preload("User").Preload("ServiceOption").First(&appointment, appointment.ID)
-	respondWithJSON(w, http.StatusCreated, appointment)
+	return booked, true
 }
 
 func (h *Handlers) GetAppointments(w http.ResponseWriter, r *http.Request) {
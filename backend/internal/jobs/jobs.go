@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn straight away and then once per interval until ctx is done.
+// Failures are logged and the job keeps running. A zero or negative
+// interval disables the job.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	if interval <= 0 {
+		log.Printf("job %s: disabled", name)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("job %s: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
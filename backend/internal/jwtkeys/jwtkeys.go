@@ -0,0 +1,264 @@
+// Package jwtkeys manages the keys used to sign and verify JWTs. Tokens are
+// signed with a single active key and verified against the active key plus
+// any previous keys still inside their rotation overlap window. Every key is
+// identified by a "kid" header so verifiers can pick the right one.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+)
+
+// Key is a single signing or verification key
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	// signKey is nil for verification-only keys
+	signKey   interface{}
+	verifyKey interface{}
+	// notAfter is when a previous key stops being accepted; zero means never
+	notAfter time.Time
+}
+
+// KeySet holds the active signing key and the keys accepted for verification
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	order   []string
+	now     func() time.Time
+}
+
+// Load builds the key set described by the configuration. Without a signing
+// key file it falls back to HS256 with JWTSecret.
+func Load(cfg *config.Config) (*KeySet, error) {
+	if cfg.JWTSigningKeyFile == "" {
+		return NewHMAC(cfg.JWTSecret), nil
+	}
+
+	signing, err := loadKeyFile(cfg.JWTSigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	if signing.signKey == nil {
+		return nil, errors.New("signing key: file does not contain a private key")
+	}
+
+	set := newKeySet(signing)
+	for _, path := range cfg.JWTPreviousKeyFiles {
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("previous key %s: %w", path, err)
+		}
+		key.signKey = nil
+		key.notAfter = cfg.JWTPreviousKeysRetireAt
+		if _, exists := set.keys[key.ID]; exists {
+			continue
+		}
+		set.add(key)
+	}
+
+	return set, nil
+}
+
+// NewHMAC returns a key set that signs and verifies with a shared HS256 secret
+func NewHMAC(secret string) *KeySet {
+	return newKeySet(&Key{
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	})
+}
+
+func newKeySet(signing *Key) *KeySet {
+	set := &KeySet{signing: signing, keys: map[string]*Key{}, now: time.Now}
+	set.add(signing)
+	return set
+}
+
+func (s *KeySet) add(key *Key) {
+	s.keys[key.ID] = key
+	s.order = append(s.order, key.ID)
+}
+
+// Sign signs the claims with the active key
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	if s.signing.ID != "" {
+		token.Header["kid"] = s.signing.ID
+	}
+	return token.SignedString(s.signing.signKey)
+}
+
+// Parse verifies a token against the accepted keys and decodes its claims
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	methods := make([]string, 0, len(s.keys))
+	for _, id := range s.order {
+		methods = append(methods, s.keys[id].Method.Alg())
+	}
+	opts = append(opts, jwt.WithValidMethods(methods))
+	return jwt.ParseWithClaims(tokenString, claims, s.keyfunc, opts...)
+}
+
+func (s *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if !key.notAfter.IsZero() && s.now().After(key.notAfter) {
+		return nil, fmt.Errorf("key %q has been retired", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is the public half of a key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys currently accepted for verification. Shared
+// HMAC secrets are never published.
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	now := s.now()
+	for _, id := range s.order {
+		key := s.keys[id]
+		if !key.notAfter.IsZero() && now.After(key.notAfter) {
+			continue
+		}
+		if jwk, ok := publicJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func publicJWK(key *Key) (JWK, bool) {
+	jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+	switch pub := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// loadKeyFile reads a PEM encoded RSA or Ed25519 key. Private keys yield a
+// signing key; public keys yield a verification-only key.
+func loadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	key.ID, err = thumbprint(key.verifyKey)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as the key ID, so
+// the same key always gets the same kid without extra configuration
+func thumbprint(pub crypto.PublicKey) (string, error) {
+	var members interface{}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{b64(big.NewInt(int64(k.E)).Bytes()), "RSA", b64(k.N.Bytes())}
+	case ed25519.PublicKey:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{"Ed25519", "OKP", b64(k)}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return b64(sum[:]), nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
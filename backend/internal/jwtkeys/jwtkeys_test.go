@@ -0,0 +1,221 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+)
+
+// newEd25519Key returns a signing key with its thumbprint as ID
+func newEd25519Key(t *testing.T) *Key {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := thumbprint(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: priv, verifyKey: pub}
+}
+
+// verifyOnly returns a copy of the key that can no longer sign, retired at notAfter
+func verifyOnly(key *Key, notAfter time.Time) *Key {
+	return &Key{ID: key.ID, Method: key.Method, verifyKey: key.verifyKey, notAfter: notAfter}
+}
+
+func sign(t *testing.T, set *KeySet) string {
+	t.Helper()
+	token, err := set.Sign(jwt.MapClaims{"sub": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	active := newEd25519Key(t)
+	previous := newEd25519Key(t)
+	retired := newEd25519Key(t)
+	stranger := newEd25519Key(t)
+
+	set := newKeySet(active)
+	set.add(verifyOnly(previous, now.Add(time.Hour)))
+	set.add(verifyOnly(retired, now.Add(-time.Minute)))
+	set.now = func() time.Time { return now }
+
+	// A token carrying the kid of a known key but signed by another
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "42"})
+	forged.Header["kid"] = active.ID
+	forgedToken, err := forged.SignedString(stranger.signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An HS256 token keyed with the public key of an asymmetric key
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "42"})
+	confused.Header["kid"] = active.ID
+	confusedToken, err := confused.SignedString([]byte(active.verifyKey.(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "42"})
+	unsigned.Header["kid"] = active.ID
+	unsignedToken, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"active key", sign(t, set), true},
+		{"previous key inside overlap", sign(t, newKeySet(previous)), true},
+		{"previous key after retirement", sign(t, newKeySet(retired)), false},
+		{"unknown key", sign(t, newKeySet(stranger)), false},
+		{"known kid with wrong signature", forgedToken, false},
+		{"algorithm confusion", confusedToken, false},
+		{"unsigned token", unsignedToken, false},
+		{"garbage", "not.a.token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{}
+			_, err := set.Parse(tt.token, claims)
+			if tt.wantOK && err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !tt.wantOK && err == nil {
+				t.Fatal("Parse accepted the token")
+			}
+			if tt.wantOK && claims["sub"] != "42" {
+				t.Errorf("sub = %v, want 42", claims["sub"])
+			}
+		})
+	}
+}
+
+func TestParseRetirementFollowsClock(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	previous := newEd25519Key(t)
+	set := newKeySet(newEd25519Key(t))
+	set.add(verifyOnly(previous, now))
+	token := sign(t, newKeySet(previous))
+
+	set.now = func() time.Time { return now }
+	if _, err := set.Parse(token, jwt.MapClaims{}); err != nil {
+		t.Errorf("rejected at the retirement time: %v", err)
+	}
+	set.now = func() time.Time { return now.Add(time.Second) }
+	if _, err := set.Parse(token, jwt.MapClaims{}); err == nil {
+		t.Error("accepted after the retirement time")
+	}
+}
+
+func TestParseHMAC(t *testing.T) {
+	set := NewHMAC("secret")
+
+	if _, err := set.Parse(sign(t, set), jwt.MapClaims{}); err != nil {
+		t.Errorf("own token rejected: %v", err)
+	}
+	if _, err := set.Parse(sign(t, NewHMAC("other secret")), jwt.MapClaims{}); err == nil {
+		t.Error("token signed with another secret accepted")
+	}
+}
+
+func TestParseAppliesOptions(t *testing.T) {
+	set := newKeySet(newEd25519Key(t))
+	token, err := set.Sign(jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{"guest"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := set.Parse(token, &jwt.RegisteredClaims{}, jwt.WithAudience("guest")); err != nil {
+		t.Errorf("matching audience rejected: %v", err)
+	}
+	if _, err := set.Parse(token, &jwt.RegisteredClaims{}, jwt.WithAudience("access")); err == nil {
+		t.Error("wrong audience accepted")
+	}
+
+	expired, err := set.Sign(jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Parse(expired, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("expired token accepted")
+	}
+}
+
+func TestLoadRotation(t *testing.T) {
+	dir := t.TempDir()
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingFile := writePEM("signing.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	_, oldPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDER, err := x509.MarshalPKCS8PrivateKey(oldPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousFile := writePEM("previous.pem", "PRIVATE KEY", oldDER)
+
+	retireAt := time.Now().Add(time.Hour)
+	set, err := Load(&config.Config{
+		JWTSigningKeyFile:       signingFile,
+		JWTPreviousKeyFiles:     []string{previousFile},
+		JWTPreviousKeysRetireAt: retireAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.signing.Method != jwt.SigningMethodRS256 {
+		t.Errorf("signing method = %s, want RS256", set.signing.Method.Alg())
+	}
+
+	previousSet, err := Load(&config.Config{JWTSigningKeyFile: previousFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, previousSet)
+	if _, err := set.Parse(token, jwt.MapClaims{}); err != nil {
+		t.Errorf("token from previous key rejected: %v", err)
+	}
+
+	set.now = func() time.Time { return retireAt.Add(time.Second) }
+	if _, err := set.Parse(token, jwt.MapClaims{}); err == nil {
+		t.Error("token from previous key accepted after retirement")
+	}
+	if got := len(set.JWKS().Keys); got != 1 {
+		t.Errorf("JWKS lists %d keys after retirement, want 1", got)
+	}
+}
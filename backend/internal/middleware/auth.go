@@ -3,64 +3,192 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/authz"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/jwtkeys"
 )
 
+// Claims are carried by access tokens. Role is the user's primary role and
+// Roles every role they currently hold.
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    uint     `json:"user_id"`
+	Email     string   `json:"email"`
+	Role      string   `json:"role"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+
+	// Set only on impersonation tokens: UserID is the effective user and
+	// ImpersonatorID the admin really making the request. Banner is the
+	// notice the frontend shows while impersonation is active.
+	ImpersonationID uint   `json:"impersonation_id,omitempty"`
+	ImpersonatorID  uint   `json:"impersonator_id,omitempty"`
+	Banner          string `json:"banner,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(secret string) func(http.Handler) http.Handler {
+// TokenValidator performs server-side checks on a token whose signature and
+// expiry are already valid, such as session revocation.
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, claims *Claims) error
+}
+
+// ImpersonationAuditor records every request made with an impersonation token
+type ImpersonationAuditor interface {
+	AuditImpersonatedRequest(r *http.Request, claims *Claims) error
+}
+
+// APIKeyPrefix marks a bearer credential as a personal API key rather than a JWT
+const APIKeyPrefix = "tbk_"
+
+// APIKeyAuthenticator resolves a personal API key to the identity and
+// scopes it grants
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*Claims, []string, error)
+}
+
+// AuthMiddleware authenticates requests with a Bearer JWT or, on endpoints
+// wrapped in APIKeyScope, with a personal API key sent either as a Bearer
+// token or in the X-API-Key header. Requests made with an impersonation token
+// are only served once the auditor has recorded them.
+func AuthMiddleware(keys *jwtkeys.KeySet, validator TokenValidator, apiKeys APIKeyAuthenticator, auditor ImpersonationAuditor) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := r.Header.Get("X-API-Key")
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			if authHeader == "" && apiKey == "" {
 				respondWithError(w, http.StatusUnauthorized, "Authorization header required")
 				return
 			}
 
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				respondWithError(w, http.StatusUnauthorized, "Invalid authorization header format")
+			var tokenString string
+			if apiKey == "" {
+				parts := strings.Split(authHeader, " ")
+				if len(parts) != 2 || parts[0] != "Bearer" {
+					respondWithError(w, http.StatusUnauthorized, "Invalid authorization header format")
+					return
+				}
+				tokenString = parts[1]
+				if strings.HasPrefix(tokenString, APIKeyPrefix) {
+					apiKey = tokenString
+				}
+			}
+
+			if apiKey != "" {
+				authenticateAPIKey(w, r, next, apiKeys, apiKey)
 				return
 			}
 
-			tokenString := parts[1]
 			claims := &Claims{}
 
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return []byte(secret), nil
-			})
+			token, err := keys.Parse(tokenString, claims)
 
 			if err != nil || !token.Valid {
 				respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
 				return
 			}
 
+			if err := validator.ValidateToken(r.Context(), claims); err != nil {
+				respondWithError(w, http.StatusUnauthorized, authErrorMessage(err, "Invalid or expired token"))
+				return
+			}
+
+			if claims.ImpersonationID != 0 {
+				if err := auditor.AuditImpersonatedRequest(r, claims); err != nil {
+					respondWithError(w, http.StatusInternalServerError, "Failed to record impersonated request")
+					return
+				}
+			}
+
 			// Add user info to context
 			ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 			ctx = context.WithValue(ctx, "user_email", claims.Email)
 			ctx = context.WithValue(ctx, "user_role", claims.Role)
+			ctx = context.WithValue(ctx, "user_roles", claims.Roles)
+			ctx = context.WithValue(ctx, "session_id", claims.SessionID)
+			if claims.ImpersonationID != 0 {
+				ctx = context.WithValue(ctx, "impersonator_id", claims.ImpersonatorID)
+			}
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func RoleMiddleware(requiredRole string) func(http.Handler) http.Handler {
+// APIKeyScope allows API keys carrying the given scope on the wrapped
+// endpoint. It must wrap AuthMiddleware; endpoints without it only accept JWTs.
+func APIKeyScope(scope string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			userRole, ok := r.Context().Value("user_role").(string)
-			if !ok || userRole != requiredRole {
+			ctx := context.WithValue(r.Context(), "api_key_scope", scope)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, apiKeys APIKeyAuthenticator, apiKey string) {
+	requiredScope, _ := r.Context().Value("api_key_scope").(string)
+	if requiredScope == "" {
+		respondWithError(w, http.StatusForbidden, "API keys cannot be used for this endpoint")
+		return
+	}
+
+	claims, scopes, err := apiKeys.AuthenticateAPIKey(r.Context(), apiKey)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, authErrorMessage(err, "Invalid API key"))
+		return
+	}
+
+	granted := false
+	for _, scope := range scopes {
+		if scope == requiredScope {
+			granted = true
+			break
+		}
+	}
+	if !granted {
+		respondWithError(w, http.StatusForbidden, "API key is missing the "+requiredScope+" scope")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
+	ctx = context.WithValue(ctx, "user_email", claims.Email)
+	ctx = context.WithValue(ctx, "user_role", claims.Role)
+	ctx = context.WithValue(ctx, "user_roles", claims.Roles)
+	ctx = context.WithValue(ctx, "api_key_scopes", scopes)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// authErrorMessage returns the message of an application error, or the
+// fallback for anything else so internal errors are not leaked
+func authErrorMessage(err error, fallback string) string {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Message
+	}
+	return fallback
+}
+
+// RequirePermission allows the request only if one of the authenticated
+// user's roles grants the permission. It must be wrapped by AuthMiddleware.
+func RequirePermission(permission authz.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			roles, _ := r.Context().Value("user_roles").([]string)
+			if !authz.HasPermission(roles, permission) {
 				respondWithError(w, http.StatusForbidden, "Insufficient permissions")
 				return
 			}
+			if _, impersonating := r.Context().Value("impersonator_id").(uint); impersonating && !authz.AllowedWhileImpersonating(permission) {
+				respondWithError(w, http.StatusForbidden, "Not available while impersonating a user")
+				return
+			}
 
 			next.ServeHTTP(w, r)
 		})
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// API key scopes. Each scope grants access to a group of master endpoints.
+const (
+	ScopeAppointmentsRead  = "appointments:read"
+	ScopeAppointmentsWrite = "appointments:write"
+	ScopeServicesRead      = "services:read"
+	ScopeServicesWrite     = "services:write"
+	ScopeTimeSlotsRead     = "time_slots:read"
+	ScopeTimeSlotsWrite    = "time_slots:write"
+)
+
+// APIKeyScopes lists every scope an API key may carry
+var APIKeyScopes = []string{
+	ScopeAppointmentsRead,
+	ScopeAppointmentsWrite,
+	ScopeServicesRead,
+	ScopeServicesWrite,
+	ScopeTimeSlotsRead,
+	ScopeTimeSlotsWrite,
+}
+
+// APIKey is a personal access key a master can give to scripts and
+// spreadsheets instead of their password. Only a hash of the key is stored;
+// Prefix identifies the key in listings.
+type APIKey struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Scopes     []string   `gorm:"type:text;serializer:json;not null" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// HasScope reports whether the key grants the given scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
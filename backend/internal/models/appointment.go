@@ -13,6 +13,16 @@ const (
 	StatusConfirmed AppointmentStatus = "confirmed"
 	StatusRejected  AppointmentStatus = "rejected"
 	StatusCancelled AppointmentStatus = "cancelled"
+
+	// A guest booking made while email verification is required. It does
+	// not block the master's calendar and becomes pending once the guest
+	// opens the link emailed to them.
+	StatusUnconfirmed AppointmentStatus = "unconfirmed"
+
+	// Set once a confirmed appointment has started, by the master or, for
+	// completed, automatically after it ends
+	StatusCompleted AppointmentStatus = "completed"
+	StatusNoShow    AppointmentStatus = "no_show"
 )
 
 type Appointment struct {
@@ -21,7 +31,10 @@ type Appointment struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	UserID          uint              `gorm:"not null" json:"user_id"`
+	// Exactly one of UserID and GuestID is set when the appointment is
+	// booked; claiming a guest booking sets UserID and keeps GuestID
+	UserID          *uint             `json:"user_id"`
+	GuestID         *uint             `gorm:"index" json:"guest_id,omitempty"`
 	MasterID        uint              `gorm:"not null" json:"master_id"`
 	ServiceID       uint              `gorm:"not null" json:"service_id"`
 	ServiceOptionID *uint             `json:"service_option_id,omitempty"`
@@ -30,9 +43,61 @@ type Appointment struct {
 	Status          AppointmentStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
 	Notes           string            `gorm:"type:text" json:"notes"`
 
+	// Set when the appointment is cancelled. CancelledByID is nil when a
+	// guest cancelled through their manage-booking link.
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty"`
+	CancelledByID      *uint      `json:"cancelled_by_id,omitempty"`
+	CancellationReason string     `gorm:"type:text" json:"cancellation_reason,omitempty"`
+
 	// Relations
 	User          User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Guest         *Guest         `gorm:"foreignKey:GuestID" json:"guest,omitempty"`
 	Master        MasterProfile  `gorm:"foreignKey:MasterID" json:"master,omitempty"`
 	Service       Service        `gorm:"foreignKey:ServiceID" json:"service,omitempty"`
 	ServiceOption *ServiceOption `gorm:"foreignKey:ServiceOptionID" json:"service_option,omitempty"`
 }
+
+// Guest is the contact details of a client who booked without an account.
+// When someone registers and verifies the same email address, the guest is
+// claimed and its appointments move to the new account.
+type Guest struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name            string     `gorm:"not null" json:"name"`
+	Email           string     `gorm:"not null;index" json:"email"`
+	Phone           string     `json:"phone"`
+	IPAddress       string     `gorm:"type:varchar(64)" json:"-"`
+	ClaimedByUserID *uint      `json:"claimed_by_user_id,omitempty"`
+	ClaimedAt       *time.Time `json:"claimed_at,omitempty"`
+}
+
+// AppointmentTransition records a change of an appointment's status.
+// ActorID is nil when a guest or the system made the change.
+type AppointmentTransition struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AppointmentID uint              `gorm:"not null;index" json:"appointment_id"`
+	FromStatus    AppointmentStatus `gorm:"type:varchar(20);not null" json:"from_status"`
+	ToStatus      AppointmentStatus `gorm:"type:varchar(20);not null" json:"to_status"`
+	ActorID       *uint             `json:"actor_id,omitempty"`
+	Reason        string            `gorm:"type:text" json:"reason,omitempty"`
+}
+
+// SlotHold reserves a time with a master for one user while they finish
+// booking. Until it expires nobody else can book that time; booking with the
+// hold turns it into an appointment.
+type SlotHold struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID          uint      `gorm:"not null;index" json:"user_id"`
+	MasterID        uint      `gorm:"not null" json:"master_id"`
+	ServiceID       uint      `gorm:"not null" json:"service_id"`
+	ServiceOptionID *uint     `json:"service_option_id,omitempty"`
+	StartTime       time.Time `gorm:"not null" json:"start_time"`
+	EndTime         time.Time `gorm:"not null" json:"end_time"`
+	ExpiresAt       time.Time `gorm:"not null;index" json:"expires_at"`
+}
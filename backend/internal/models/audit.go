@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditLoginLocked   = "login.locked"
+	AuditLoginUnlocked = "login.unlocked"
+	AuditRoleGranted   = "role.granted"
+	AuditRoleRevoked   = "role.revoked"
+
+	AuditImpersonationStarted = "impersonation.started"
+	AuditImpersonationEnded   = "impersonation.ended"
+	AuditImpersonationRequest = "impersonation.request"
+
+	AuditAccountDeleted      = "account.deleted"
+	AuditAccountEmailChanged = "account.email_changed"
+
+	AuditUserSuspended   = "user.suspended"
+	AuditUserUnsuspended = "user.unsuspended"
+	AuditUserDeleted     = "user.deleted"
+	AuditUserRestored    = "user.restored"
+)
+
+// AuditLog is an append-only record of a security-relevant event
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	// ActorID is the user who caused the event; nil for system events
+	ActorID    *uint  `gorm:"index" json:"actor_id,omitempty"`
+	Action     string `gorm:"type:varchar(64);not null;index" json:"action"`
+	TargetType string `gorm:"type:varchar(32)" json:"target_type"`
+	TargetID   string `gorm:"type:varchar(255)" json:"target_id"`
+	IPAddress  string `gorm:"type:varchar(64)" json:"ip_address"`
+	Details    string `gorm:"type:text" json:"details"`
+}
@@ -0,0 +1,197 @@
+package models
+
+import "time"
+
+// RefreshToken is an opaque, single-use credential that can be exchanged for
+// a new access token. Every token issued from one login shares a SessionID,
+// so presenting an already rotated token revokes the whole chain.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	SessionID string     `gorm:"type:varchar(64);not null;index" json:"session_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// Invitation lets an admin grant a role (including admin) to someone who
+// cannot obtain it through self-registration. The invite token itself is a
+// signed JWT; only a hash of its nonce is stored so it can be used once.
+type Invitation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Email          string     `gorm:"not null;index" json:"email"`
+	Role           UserRole   `gorm:"type:varchar(20);not null" json:"role"`
+	InvitedByID    uint       `gorm:"not null" json:"invited_by_id"`
+	NonceHash      string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedUserID *uint      `json:"accepted_user_id,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+
+	// Relations
+	InvitedBy User `gorm:"foreignKey:InvitedByID" json:"-"`
+}
+
+type TokenPurpose string
+
+const (
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+	TokenPurposeEmailChange       TokenPurpose = "email_change"
+)
+
+// UserToken is a hashed, expiring, single-use token sent to a user out of
+// band, e.g. in a password reset or email verification link
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint         `gorm:"not null;index" json:"user_id"`
+	Purpose   TokenPurpose `gorm:"type:varchar(32);not null" json:"purpose"`
+	TokenHash string       `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time    `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time   `json:"used_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+type ThrottleScope string
+
+const (
+	ThrottleScopeAccount      ThrottleScope = "account"
+	ThrottleScopeIP           ThrottleScope = "ip"
+	ThrottleScopeMFAChallenge ThrottleScope = "mfa_challenge"
+)
+
+// LoginThrottle tracks recent failed logins for one account (keyed by
+// normalized email), one client address or one MFA challenge (keyed by its
+// token ID). It lives in the database so that every replica sees the same
+// counters.
+type LoginThrottle struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Scope          ThrottleScope `gorm:"type:varchar(16);not null;uniqueIndex:idx_login_throttles_scope_key" json:"scope"`
+	Key            string        `gorm:"type:varchar(255);not null;uniqueIndex:idx_login_throttles_scope_key" json:"key"`
+	FailedAttempts int           `gorm:"not null;default:0" json:"failed_attempts"`
+	LastFailedAt   *time.Time    `json:"last_failed_at,omitempty"`
+	LockedUntil    *time.Time    `json:"locked_until,omitempty"`
+}
+
+// RecoveryCode is a hashed single-use code that stands in for a TOTP code
+// when the user has lost their authenticator
+type RecoveryCode struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID   uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// OIDCLoginState carries a pending single sign-on attempt from the redirect
+// to the identity provider until the callback. The state value is stored
+// hashed; the nonce and PKCE verifier never leave the server.
+type OIDCLoginState struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	StateHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Nonce        string     `gorm:"type:varchar(64);not null" json:"-"`
+	CodeVerifier string     `gorm:"type:varchar(128);not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+}
+
+// TableName keeps GORM from splitting the OIDC acronym
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// UserIdentity links a user to an account at an external identity provider
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Issuer      string     `gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject" json:"issuer"`
+	Subject     string     `gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject" json:"subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// Session is one login on one device. Its SessionID is embedded in every
+// access token ("sid") and shared by the session's refresh tokens, so
+// revoking the session signs that device out everywhere.
+type Session struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	SessionID  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	UserAgent  string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IPAddress  string     `gorm:"type:varchar(64)" json:"ip_address"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// Impersonation records an admin acting as another user. Tokens issued for
+// it carry its ID and stop working once it ends or expires, or once the
+// admin's own session is revoked.
+type Impersonation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	AdminID        uint       `gorm:"not null;index" json:"admin_id"`
+	AdminSessionID string     `gorm:"type:varchar(64);not null" json:"-"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	Reason         string     `gorm:"type:text;not null" json:"reason"`
+	IPAddress      string     `gorm:"type:varchar(64)" json:"ip_address"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+
+	// Relations
+	Admin User `gorm:"foreignKey:AdminID" json:"-"`
+	User  User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// PhoneLoginCode is a one-time code texted to a phone number for
+// passwordless login. Only a hash of the code is stored.
+type PhoneLoginCode struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Phone     string     `gorm:"type:varchar(16);not null;index" json:"phone"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	IPAddress string     `gorm:"type:varchar(64);index" json:"ip_address"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
@@ -14,6 +14,17 @@ const (
 	RoleAdmin  UserRole = "admin"
 )
 
+// RoleGrant gives a user a role on top of their primary User.Role, e.g. a
+// master who also books appointments as a client
+type RoleGrant struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID      uint     `gorm:"not null;uniqueIndex:idx_role_grants_user_role" json:"user_id"`
+	Role        UserRole `gorm:"type:varchar(20);not null;uniqueIndex:idx_role_grants_user_role" json:"role"`
+	GrantedByID *uint    `json:"granted_by_id,omitempty"`
+}
+
 type User struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -26,6 +37,36 @@ type User struct {
 	Role     UserRole `gorm:"type:varchar(20);not null;default:'user'" json:"role"`
 	Phone    string   `json:"phone"`
 
+	// PasswordUnset is true while Password is a random one the user was
+	// never told, as for accounts created through OpenID Connect. It is
+	// cleared when they reset their password.
+	PasswordUnset bool `gorm:"not null;default:false" json:"password_unset"`
+
+	// VerifiedAt is set once the user has confirmed they own Email
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+
+	// PendingEmail is an address the user asked to change to. It replaces
+	// Email once confirmed from a link sent to the new address.
+	PendingEmail string `json:"pending_email,omitempty"`
+
+	// AnonymizedAt is set when the user deleted their account. The row stays
+	// so appointments keep their client, but all personal data is removed.
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+
+	// SuspendedAt is set while an admin has suspended the account. A
+	// suspended user cannot log in and their tokens stop working.
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason string     `json:"suspended_reason,omitempty"`
+
+	// Two-factor authentication. TOTPSecret is set during enrollment and
+	// only takes effect once TOTPEnabledAt is set.
+	TOTPSecret    string     `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at,omitempty"`
+	TOTPLastStep  int64      `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+
+	// RoleGrants holds roles the user has in addition to Role
+	RoleGrants []RoleGrant `gorm:"foreignKey:UserID" json:"-"`
+
 	// Master-specific fields
 	MasterProfile *MasterProfile `gorm:"foreignKey:UserID" json:"master_profile,omitempty"`
 
@@ -45,7 +86,51 @@ type MasterProfile struct {
 	Specialty  string `json:"specialty"`
 	Experience int    `json:"experience"` // years of experience
 
+	// CancellationNoticeHours is how long before the start clients must
+	// cancel at the latest; 0 allows cancelling until the appointment starts
+	CancellationNoticeHours int `gorm:"not null;default:0" json:"cancellation_notice_hours"`
+
+	// Working hours, as whole hours of the day in the master's time zone.
+	// Appointments can only be booked between WorkdayStartHour and
+	// WorkdayEndHour. An empty TimeZone means the server's
+	// DEFAULT_TIME_ZONE.
+	TimeZone         string `gorm:"type:varchar(64);not null;default:''" json:"time_zone"`
+	WorkdayStartHour int    `gorm:"not null;default:8" json:"workday_start_hour"`
+	WorkdayEndHour   int    `gorm:"not null;default:22" json:"workday_end_hour"`
+
 	// Relations
 	Services     []Service     `gorm:"foreignKey:MasterID" json:"services,omitempty"`
 	Appointments []Appointment `gorm:"foreignKey:MasterID" json:"appointments,omitempty"`
 }
+
+// TwoFactorEnabled reports whether the user must present a second factor at login
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// Suspended reports whether an admin has suspended the account
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// Roles returns the primary role followed by any granted roles. RoleGrants
+// must be preloaded.
+func (u *User) Roles() []UserRole {
+	roles := []UserRole{u.Role}
+	for _, grant := range u.RoleGrants {
+		if grant.Role != u.Role {
+			roles = append(roles, grant.Role)
+		}
+	}
+	return roles
+}
+
+// HasRole reports whether the user holds the role. RoleGrants must be preloaded.
+func (u *User) HasRole(role UserRole) bool {
+	for _, r := range u.Roles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
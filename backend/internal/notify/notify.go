@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+)
+
+// Message is a notification addressed to a single recipient
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Notifier delivers messages to users (email, chat, ...)
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier selected by configuration
+func New(cfg *config.Config) (Notifier, error) {
+	switch cfg.NotifierDriver {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "file":
+		return NewFileNotifier(cfg.NotifierFilePath), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", cfg.NotifierDriver)
+	}
+}
+
+// LogNotifier writes messages to the application log. It is the default
+// driver so that development setups need no mail server.
+type LogNotifier struct{}
+
+// Send logs the message
+func (LogNotifier) Send(ctx context.Context, msg Message) error {
+	log.Printf("notification to=%s subject=%q body=%q", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileNotifier appends messages as JSON lines to a local file, which makes
+// delivered messages easy to inspect in tests and offline setups
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier creates a notifier writing to the given path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Send appends the message to the file
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(struct {
+		SentAt time.Time `json:"sent_at"`
+		Message
+	}{SentAt: time.Now(), Message: msg})
+}
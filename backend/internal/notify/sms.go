@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+)
+
+// SMS is a text message addressed to an E.164 phone number
+type SMS struct {
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+// SMSSender delivers text messages to phones
+type SMSSender interface {
+	SendSMS(ctx context.Context, msg SMS) error
+}
+
+// NewSMSSender returns the SMS sender selected by configuration
+func NewSMSSender(cfg *config.Config) (SMSSender, error) {
+	switch cfg.SMSDriver {
+	case "", "log":
+		return LogSMSSender{}, nil
+	case "file":
+		return NewFileSMSSender(cfg.SMSFilePath), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS driver %q", cfg.SMSDriver)
+	}
+}
+
+// LogSMSSender writes text messages, including any one-time codes, to the
+// application log. It is the default driver so that phone login can be
+// tried out without an SMS gateway.
+type LogSMSSender struct{}
+
+// SendSMS logs the message
+func (LogSMSSender) SendSMS(ctx context.Context, msg SMS) error {
+	log.Printf("sms to=%s body=%q", msg.To, msg.Body)
+	return nil
+}
+
+// FileSMSSender appends text messages as JSON lines to a local file
+type FileSMSSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileSMSSender creates an SMS sender writing to the given path
+func NewFileSMSSender(path string) *FileSMSSender {
+	return &FileSMSSender{path: path}
+}
+
+// SendSMS appends the message to the file
+func (s *FileSMSSender) SendSMS(ctx context.Context, msg SMS) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(struct {
+		SentAt time.Time `json:"sent_at"`
+		SMS
+	}{SentAt: time.Now(), SMS: msg})
+}
@@ -0,0 +1,476 @@
+// Package oidc is a minimal OpenID Connect relying party. It discovers the
+// provider's endpoints, builds authorization-code requests protected with
+// PKCE, exchanges codes for ID tokens and validates them against the
+// provider's published keys. The HTTP client is injectable so the flow can be
+// exercised against a local mock identity provider.
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+)
+
+const (
+	// keyRefreshInterval limits how often an unknown kid triggers a JWKS refetch
+	keyRefreshInterval = time.Minute
+	// clockSkew is tolerated when checking ID token timestamps
+	clockSkew = time.Minute
+	// maxResponseSize caps how much of a provider response is read
+	maxResponseSize = 1 << 20
+)
+
+// ErrNotConfigured is returned when no provider is configured
+var ErrNotConfigured = errors.New("oidc: provider not configured")
+
+// Metadata is the subset of the provider's discovery document we use
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// IDToken holds the validated claims of an ID token
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string      `json:"nonce"`
+	AuthorizedParty string      `json:"azp"`
+	Email           string      `json:"email"`
+	EmailVerified   interface{} `json:"email_verified"`
+	Name            string      `json:"name"`
+}
+
+// Provider talks to a single OpenID Connect identity provider
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	client       *http.Client
+	now          func() time.Time
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// New returns a provider described by the configuration, or nil when OIDC
+// is not configured. A nil client means http.DefaultClient.
+func New(cfg *config.Config, client *http.Client) *Provider {
+	if cfg.OIDCIssuerURL == "" {
+		return nil
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Provider{
+		issuer:       cfg.OIDCIssuerURL,
+		clientID:     cfg.OIDCClientID,
+		clientSecret: cfg.OIDCClientSecret,
+		redirectURL:  cfg.OIDCRedirectURL,
+		scopes:       cfg.OIDCScopes,
+		client:       client,
+		now:          time.Now,
+	}
+}
+
+// Issuer returns the provider's issuer identifier
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// Discover fetches and caches the provider's discovery document
+func (p *Provider) Discover(ctx context.Context) (*Metadata, error) {
+	if p == nil {
+		return nil, ErrNotConfigured
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var metadata Metadata
+	endpoint := strings.TrimRight(p.issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, endpoint, &metadata); err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if metadata.Issuer != p.issuer {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match configured issuer %q", metadata.Issuer, p.issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: document is missing required endpoints")
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// AuthCodeURL builds the URL the user is sent to for authentication
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the validated ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDToken, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.clientSecret == "" {
+		form.Set("client_id", p.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token request: unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// VerifyIDToken checks an ID token's signature, issuer, audience, lifetime
+// and nonce, and returns its claims
+func (p *Provider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (*IDToken, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := metadata.SigningAlgs
+	if len(methods) == 0 {
+		methods = []string{"RS256"}
+	}
+
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return p.verificationKey(ctx, token)
+		},
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: id token has no subject")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("oidc: id token nonce mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.clientID {
+		return nil, errors.New("oidc: id token authorized party mismatch")
+	}
+
+	return &IDToken{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// verificationKey picks the provider key for a token, refetching the key set
+// once if the kid is unknown so provider key rotation is picked up
+func (p *Provider) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.lookupKey(kid)
+	if !ok && p.now().Sub(p.keysFetchedAt) >= keyRefreshInterval {
+		if err := p.fetchKeys(ctx); err != nil {
+			return nil, err
+		}
+		key, ok = p.lookupKey(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if !keyMatchesMethod(key, token.Method) {
+		return nil, fmt.Errorf("key %q cannot verify %s", kid, token.Method.Alg())
+	}
+	return key, nil
+}
+
+// lookupKey finds a key by kid; tokens without a kid are accepted only when
+// the provider publishes a single key
+func (p *Provider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// fetchKeys replaces the cached key set; callers must hold p.mu
+func (p *Provider) fetchKeys(ctx context.Context) error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return fmt.Errorf("oidc: fetching keys: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			// Skip key types we do not support rather than failing the set
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+	return nil
+}
+
+func parseJWK(jwk jsonWebKey) (interface{}, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+}
+
+func keyMatchesMethod(key interface{}, method jwt.SigningMethod) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		_, rsaPKCS := method.(*jwt.SigningMethodRSA)
+		_, rsaPSS := method.(*jwt.SigningMethodRSAPSS)
+		return rsaPKCS || rsaPSS
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	case ed25519.PublicKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636)
+func NewCodeVerifier() (string, error) {
+	return randomString(32)
+}
+
+// CodeChallenge derives the S256 PKCE challenge for a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewNonce returns a random value for the state or nonce parameters
+func NewNonce() (string, error) {
+	return randomString(32)
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// isTrue accepts email_verified as a JSON boolean or, as some providers send
+// it, the string "true"
+func isTrue(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+)
+
+const (
+	testClientID = "timebook"
+	testKeyID    = "key-1"
+	testNonce    = "nonce-123"
+)
+
+// mockProvider is a local identity provider serving discovery, keys and a
+// token endpoint that only accepts the expected PKCE verifier
+type mockProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	verifier string
+	idToken  string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/keys",
+			SigningAlgs:           []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			KeyType: "RSA",
+			KeyID:   testKeyID,
+			Use:     "sig",
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("code_verifier") != m.verifier {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": m.idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockProvider) provider() *Provider {
+	return New(&config.Config{
+		OIDCIssuerURL:   m.server.URL,
+		OIDCClientID:    testClientID,
+		OIDCRedirectURL: "http://localhost/callback",
+		OIDCScopes:      []string{"openid", "email"},
+	}, m.server.Client())
+}
+
+// claims returns valid ID token claims for the mock provider
+func (m *mockProvider) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          testNonce,
+		"email":          "ann@example.com",
+		"email_verified": true,
+	}
+}
+
+func (m *mockProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// S256 is the unpadded base64url SHA-256 of the verifier; this is the
+	// well-known SHA-256 digest of "abc"
+	got := CodeChallenge("abc")
+	if want := "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0"; got != want {
+		t.Errorf("CodeChallenge = %s, want %s", got, want)
+	}
+}
+
+func TestNewCodeVerifier(t *testing.T) {
+	a, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("NewCodeVerifier returned the same verifier twice")
+	}
+	// RFC 7636 requires 43 to 128 characters
+	if len(a) < 43 || len(a) > 128 {
+		t.Errorf("verifier length = %d, want 43 to 128", len(a))
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := m.provider().AuthCodeURL(context.Background(), "state-1", testNonce, CodeChallenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.server.URL+"/authorize" {
+		t.Errorf("endpoint = %s, want %s/authorize", got, m.server.URL)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge":        CodeChallenge(verifier),
+		"code_challenge_method": "S256",
+		"scope":                 "openid email",
+	}
+	for name, value := range want {
+		if got := u.Query().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider()
+	p.issuer = m.server.URL + "/other"
+
+	if _, err := p.Discover(context.Background()); err == nil {
+		t.Error("Discover accepted a document for another issuer")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	m := newMockProvider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims())
+	foreign.Header["kid"] = testKeyID
+	foreignToken, err := foreign.SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, m.claims())
+	hmac.Header["kid"] = testKeyID
+	hmacToken, err := hmac.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(change func(jwt.MapClaims)) string {
+		claims := m.claims()
+		change(claims)
+		return m.sign(t, claims)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		nonce  string
+		wantOK bool
+	}{
+		{"valid", m.sign(t, m.claims()), testNonce, true},
+		{"wrong nonce", m.sign(t, m.claims()), "other-nonce", false},
+		{"missing nonce", with(func(c jwt.MapClaims) { delete(c, "nonce") }), testNonce, false},
+		{"wrong issuer", with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }), testNonce, false},
+		{"wrong audience", with(func(c jwt.MapClaims) { c["aud"] = "someone-else" }), testNonce, false},
+		{"expired", with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }), testNonce, false},
+		{"no expiry", with(func(c jwt.MapClaims) { delete(c, "exp") }), testNonce, false},
+		{"issued in the future", with(func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }), testNonce, false},
+		{"no subject", with(func(c jwt.MapClaims) { delete(c, "sub") }), testNonce, false},
+		{"several audiences without azp", with(func(c jwt.MapClaims) { c["aud"] = []string{testClientID, "other"} }), testNonce, false},
+		{"several audiences with azp", with(func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = testClientID
+		}), testNonce, true},
+		{"signed by another key", foreignToken, testNonce, false},
+		{"algorithm not offered", hmacToken, testNonce, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := m.provider().VerifyIDToken(context.Background(), tt.token, tt.nonce)
+			if tt.wantOK {
+				if err != nil {
+					t.Fatalf("VerifyIDToken: %v", err)
+				}
+				if token.Subject != "user-1" || token.Email != "ann@example.com" || !token.EmailVerified {
+					t.Errorf("claims = %+v", token)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("VerifyIDToken accepted the token")
+			}
+		})
+	}
+}
+
+func TestExchangeSendsCodeVerifier(t *testing.T) {
+	m := newMockProvider(t)
+	m.verifier = "verifier-123"
+	m.idToken = m.sign(t, m.claims())
+
+	if _, err := m.provider().Exchange(context.Background(), "code", "verifier-123", testNonce); err != nil {
+		t.Errorf("Exchange with the right verifier: %v", err)
+	}
+	_, err := m.provider().Exchange(context.Background(), "code", "wrong-verifier", testNonce)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Exchange with the wrong verifier = %v, want status 400", err)
+	}
+}
+
+func TestIsTrue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{true, true},
+		{false, false},
+		{"true", true},
+		{"false", false},
+		{"TRUE", false},
+		{1, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isTrue(tt.value); got != tt.want {
+			t.Errorf("isTrue(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
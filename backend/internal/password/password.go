@@ -0,0 +1,118 @@
+// Package password enforces the password policy and owns password hashing,
+// so every place that sets or checks a password applies the same rules and
+// bcrypt cost.
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxLength is bcrypt's input limit; longer passwords would be silently truncated
+const maxLength = 72
+
+//go:embed breached.txt
+var bundledBreached string
+
+// Policy violations
+var (
+	ErrRequired = apperrors.New("VALIDATION_ERROR", "Password is required", http.StatusBadRequest)
+	ErrTooLong  = apperrors.New("PASSWORD_TOO_LONG", fmt.Sprintf("Password must be at most %d bytes", maxLength), http.StatusBadRequest)
+	ErrBreached = apperrors.New("PASSWORD_BREACHED", "This password has appeared in a data breach; please choose another", http.StatusBadRequest)
+)
+
+// Policy validates and hashes passwords
+type Policy struct {
+	minLength int
+	cost      int
+	breached  map[string]struct{}
+}
+
+// NewPolicy builds the policy described by the configuration. The bundled
+// breached-password list is always loaded; PasswordBreachedListFile adds to it.
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	policy := &Policy{
+		minLength: cfg.PasswordMinLength,
+		cost:      cfg.BcryptCost,
+		breached:  map[string]struct{}{},
+	}
+	if err := policy.loadBreached(strings.NewReader(bundledBreached)); err != nil {
+		return nil, err
+	}
+
+	if cfg.PasswordBreachedListFile != "" {
+		f, err := os.Open(cfg.PasswordBreachedListFile)
+		if err != nil {
+			return nil, fmt.Errorf("breached password list: %w", err)
+		}
+		defer f.Close()
+		if err := policy.loadBreached(f); err != nil {
+			return nil, fmt.Errorf("breached password list: %w", err)
+		}
+	}
+
+	return policy, nil
+}
+
+func (p *Policy) loadBreached(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate checks a new password against the policy
+func (p *Policy) Validate(password string) error {
+	if password == "" {
+		return ErrRequired
+	}
+	if len([]rune(password)) < p.minLength {
+		return apperrors.New("PASSWORD_TOO_SHORT", fmt.Sprintf("Password must be at least %d characters", p.minLength), http.StatusBadRequest)
+	}
+	if len(password) > maxLength {
+		return ErrTooLong
+	}
+	if _, found := p.breached[strings.ToLower(password)]; found {
+		return ErrBreached
+	}
+	return nil
+}
+
+// Hash hashes a password at the configured cost
+func (p *Policy) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), p.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Compare reports whether the password matches the hash
+func (p *Policy) Compare(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NeedsRehash reports whether a hash was made at a lower cost than the
+// policy's and should be replaced the next time the password is known
+func (p *Policy) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost < p.cost
+}
@@ -0,0 +1,54 @@
+// Package phone normalizes user-entered phone numbers to E.164
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned for input that cannot be turned into an E.164 number
+var ErrInvalid = errors.New("invalid phone number")
+
+// ErrNoCountryCode is returned for a national number when no default country
+// calling code is configured
+var ErrNoCountryCode = errors.New("phone number must include a country code")
+
+// Normalize converts a phone number to E.164 ("+" followed by up to 15
+// digits). Spaces, dashes, dots and parentheses are ignored. A leading "00"
+// is treated as the international prefix. Numbers without either are taken
+// to be national numbers in the default country: a single trunk "0" is
+// dropped and defaultCountryCode (e.g. "44") is prepended.
+func Normalize(raw, defaultCountryCode string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalid
+		}
+	}
+	number := b.String()
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		if defaultCountryCode == "" {
+			return "", ErrNoCountryCode
+		}
+		number = strings.TrimPrefix(defaultCountryCode, "+") + strings.TrimPrefix(number, "0")
+	}
+
+	// Country codes never start with 0; the shortest numbers in use have 8
+	// digits including the country code
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalid
+	}
+	return "+" + number, nil
+}
@@ -0,0 +1,50 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name           string
+		raw            string
+		defaultCountry string
+		want           string
+		wantErr        error
+	}{
+		{"already E.164", "+447700900123", "", "+447700900123", nil},
+		{"separators", "+44 (7700) 900-123", "", "+447700900123", nil},
+		{"dots", "+1.415.555.0132", "", "+14155550132", nil},
+		{"surrounding spaces", "  +447700900123 ", "", "+447700900123", nil},
+		{"international prefix", "00447700900123", "", "+447700900123", nil},
+		{"national number with trunk zero", "07700 900123", "44", "+447700900123", nil},
+		{"national number without trunk zero", "4155550132", "1", "+14155550132", nil},
+		{"default country with plus", "07700900123", "+44", "+447700900123", nil},
+		{"international number ignores default", "+14155550132", "44", "+14155550132", nil},
+		{"fifteen digits", "+123456789012345", "", "+123456789012345", nil},
+		{"eight digits", "+12345678", "", "+12345678", nil},
+
+		{"national number without default", "07700900123", "", "", ErrNoCountryCode},
+		{"empty without default", "", "", "", ErrNoCountryCode},
+		{"letters", "+44 7700 CALL ME", "", "", ErrInvalid},
+		{"plus in the middle", "44+7700900123", "", "", ErrInvalid},
+		{"two pluses", "++447700900123", "", "", ErrInvalid},
+		{"sixteen digits", "+1234567890123456", "", "", ErrInvalid},
+		{"seven digits", "+1234567", "", "", ErrInvalid},
+		{"country code starting with zero", "+0447700900123", "", "", ErrInvalid},
+		{"plus only", "+", "", "", ErrInvalid},
+		{"international prefix only", "00", "", "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.raw, tt.defaultCountry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Normalize(%q, %q) error = %v, want %v", tt.raw, tt.defaultCountry, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q, %q) = %q, want %q", tt.raw, tt.defaultCountry, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository defines the interface for API key data access
+type APIKeyRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, key *models.APIKey) error
+	GetByHash(ctx context.Context, tx *gorm.DB, keyHash string) (*models.APIKey, error)
+	GetByIDForUser(ctx context.Context, tx *gorm.DB, id, userID uint) (*models.APIKey, error)
+	ListForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.APIKey, error)
+	Update(ctx context.Context, tx *gorm.DB, key *models.APIKey) error
+	TouchLastUsed(ctx context.Context, tx *gorm.DB, id uint, usedAt time.Time) error
+	RevokeAllForUser(ctx context.Context, tx *gorm.DB, userID uint) error
+}
+
+type apiKeyRepo struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepo{db: db}
+}
+
+// Create stores a new API key
+func (r *apiKeyRepo) Create(ctx context.Context, tx *gorm.DB, key *models.APIKey) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(key).Error
+}
+
+// GetByHash retrieves an API key by the hash of its secret
+func (r *apiKeyRepo) GetByHash(ctx context.Context, tx *gorm.DB, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("key_hash = ?", keyHash).First(&key).Error
+	return &key, err
+}
+
+// GetByIDForUser retrieves an API key by ID, ensuring it belongs to the user
+func (r *apiKeyRepo) GetByIDForUser(ctx context.Context, tx *gorm.DB, id, userID uint) (*models.APIKey, error) {
+	var key models.APIKey
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&key).Error
+	return &key, err
+}
+
+// ListForUser retrieves a user's API keys, newest first
+func (r *apiKeyRepo) ListForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Update updates an existing API key
+func (r *apiKeyRepo) Update(ctx context.Context, tx *gorm.DB, key *models.APIKey) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(key).Error
+}
+
+// TouchLastUsed records when a key was last used without touching other columns
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, tx *gorm.DB, id uint, usedAt time.Time) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error
+}
+
+// RevokeAllForUser revokes every active key belonging to a user
+func (r *apiKeyRepo) RevokeAllForUser(ctx context.Context, tx *gorm.DB, userID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *apiKeyRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -2,17 +2,43 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/timebook/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// exclusionViolation is the Postgres error code raised when a row breaks an
+// exclusion constraint
+const exclusionViolation = "23P01"
+
+// ErrAppointmentOverlap is returned when saving an appointment would overlap
+// another active appointment of the same master
+var ErrAppointmentOverlap = errors.New("appointment overlaps another appointment of the master")
+
 // AppointmentRepository defines the interface for appointment data access
 type AppointmentRepository interface {
 	GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Appointment, error)
+	GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Appointment, error)
+	ListActiveForMasterBetween(ctx context.Context, tx *gorm.DB, masterID uint, from, to time.Time) ([]*models.Appointment, error)
 	Create(ctx context.Context, tx *gorm.DB, appointment *models.Appointment) error
 	Update(ctx context.Context, tx *gorm.DB, appointment *models.Appointment) error
 	List(ctx context.Context, tx *gorm.DB, filters map[string]interface{}) ([]*models.Appointment, error)
+	ListConfirmedEndedBefore(ctx context.Context, tx *gorm.DB, before time.Time, limit int) ([]*models.Appointment, error)
+	CountNoShowsForMaster(ctx context.Context, tx *gorm.DB, masterID uint) (*NoShowCounts, error)
+	ClearNotesForUser(ctx context.Context, tx *gorm.DB, userID uint) error
+}
+
+// NoShowCounts is how often each of a master's clients missed an
+// appointment. Guests are counted by email address until their bookings are
+// claimed by an account.
+type NoShowCounts struct {
+	ByUser       map[uint]int64
+	ByGuestEmail map[string]int64
 }
 
 type appointmentRepo struct {
@@ -28,20 +54,44 @@ func NewAppointmentRepository(db *gorm.DB) AppointmentRepository {
 func (r *appointmentRepo) GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Appointment, error) {
 	var appointment models.Appointment
 	db := r.getDB(tx)
-	err := db.WithContext(ctx).Preload("User").Preload("Service").Preload("Master").First(&appointment, id).Error
+	err := db.WithContext(ctx).Preload("User").Preload("Guest").Preload("Service").Preload("ServiceOption").Preload("Master").First(&appointment, id).Error
 	return &appointment, err
 }
 
-// Create creates a new appointment
+// GetByIDForUpdate retrieves an appointment by ID and locks the row
+func (r *appointmentRepo) GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Appointment, error) {
+	var appointment models.Appointment
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("User").Preload("Guest").Preload("Service").Preload("ServiceOption").Preload("Master").
+		First(&appointment, id).Error
+	return &appointment, err
+}
+
+// ListActiveForMasterBetween retrieves the master's pending and confirmed
+// appointments overlapping the given time
+func (r *appointmentRepo) ListActiveForMasterBetween(ctx context.Context, tx *gorm.DB, masterID uint, from, to time.Time) ([]*models.Appointment, error) {
+	var appointments []*models.Appointment
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where(
+		"master_id = ? AND status IN ? AND start_time < ? AND end_time > ?",
+		masterID, []models.AppointmentStatus{models.StatusPending, models.StatusConfirmed}, to, from,
+	).Order("start_time").Find(&appointments).Error
+	return appointments, err
+}
+
+// Create creates a new appointment. It returns ErrAppointmentOverlap when
+// the master is already booked at that time.
 func (r *appointmentRepo) Create(ctx context.Context, tx *gorm.DB, appointment *models.Appointment) error {
 	db := r.getDB(tx)
-	return db.WithContext(ctx).Create(appointment).Error
+	return translateOverlap(db.WithContext(ctx).Create(appointment).Error)
 }
 
-// Update updates an existing appointment
+// Update updates an existing appointment. It returns ErrAppointmentOverlap
+// when the master is already booked at the appointment's time.
 func (r *appointmentRepo) Update(ctx context.Context, tx *gorm.DB, appointment *models.Appointment) error {
 	db := r.getDB(tx)
-	return db.WithContext(ctx).Save(appointment).Error
+	return translateOverlap(db.WithContext(ctx).Save(appointment).Error)
 }
 
 // List retrieves appointments based on filters
@@ -53,10 +103,84 @@ func (r *appointmentRepo) List(ctx context.Context, tx *gorm.DB, filters map[str
 		db = db.Where(key+" = ?", value)
 	}
 
-	err := db.Preload("User").Preload("Service").Preload("Master").Find(&appointments).Error
+	err := db.Preload("User").Preload("Guest").Preload("Service").Preload("Master").Find(&appointments).Error
+	return appointments, err
+}
+
+// ListConfirmedEndedBefore retrieves confirmed appointments that ended
+// before the given time, oldest first
+func (r *appointmentRepo) ListConfirmedEndedBefore(ctx context.Context, tx *gorm.DB, before time.Time, limit int) ([]*models.Appointment, error) {
+	var appointments []*models.Appointment
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("status = ? AND end_time < ?", models.StatusConfirmed, before).
+		Order("end_time").Limit(limit).Find(&appointments).Error
 	return appointments, err
 }
 
+// CountNoShowsForMaster counts the no-shows of each of the master's clients
+func (r *appointmentRepo) CountNoShowsForMaster(ctx context.Context, tx *gorm.DB, masterID uint) (*NoShowCounts, error) {
+	db := r.getDB(tx).WithContext(ctx)
+	counts := &NoShowCounts{ByUser: map[uint]int64{}, ByGuestEmail: map[string]int64{}}
+
+	var users []struct {
+		UserID uint
+		Count  int64
+	}
+	if err := db.Model(&models.Appointment{}).Select("user_id, COUNT(*) AS count").
+		Where("master_id = ? AND status = ? AND user_id IS NOT NULL", masterID, models.StatusNoShow).
+		Group("user_id").Scan(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range users {
+		counts.ByUser[row.UserID] = row.Count
+	}
+
+	var guests []struct {
+		Email string
+		Count int64
+	}
+	if err := db.Model(&models.Appointment{}).Select("LOWER(guests.email) AS email, COUNT(*) AS count").
+		Joins("JOIN guests ON guests.id = appointments.guest_id").
+		Where("appointments.master_id = ? AND appointments.status = ? AND appointments.user_id IS NULL", masterID, models.StatusNoShow).
+		Group("LOWER(guests.email)").Scan(&guests).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range guests {
+		counts.ByGuestEmail[row.Email] = row.Count
+	}
+
+	return counts, nil
+}
+
+// For returns the number of no-shows of the appointment's client
+func (c *NoShowCounts) For(appointment *models.Appointment) int64 {
+	if appointment.UserID != nil {
+		return c.ByUser[*appointment.UserID]
+	}
+	if appointment.Guest != nil {
+		return c.ByGuestEmail[strings.ToLower(appointment.Guest.Email)]
+	}
+	return 0
+}
+
+// ClearNotesForUser blanks the notes on every appointment booked by a user
+func (r *appointmentRepo) ClearNotesForUser(ctx context.Context, tx *gorm.DB, userID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.Appointment{}).
+		Where("user_id = ? AND notes <> ''", userID).
+		Update("notes", "").Error
+}
+
+// translateOverlap turns a violation of the appointments_no_overlap
+// constraint into ErrAppointmentOverlap
+func translateOverlap(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == exclusionViolation {
+		return ErrAppointmentOverlap
+	}
+	return err
+}
+
 // getDB returns the transaction if provided, otherwise returns the default DB
 func (r *appointmentRepo) getDB(tx *gorm.DB) *gorm.DB {
 	if tx != nil {
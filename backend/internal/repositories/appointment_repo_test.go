@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestTranslateOverlap(t *testing.T) {
+	overlap := &pgconn.PgError{Code: exclusionViolation, ConstraintName: "appointments_no_overlap"}
+	unique := &pgconn.PgError{Code: "23505", ConstraintName: "appointments_pkey"}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil", nil, nil},
+		{"exclusion violation", overlap, ErrAppointmentOverlap},
+		{"wrapped exclusion violation", fmt.Errorf("create appointment: %w", overlap), ErrAppointmentOverlap},
+		{"other constraint violation", unique, unique},
+		{"not found", gorm.ErrRecordNotFound, gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translateOverlap(tt.err)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("translateOverlap = %v, want nil", got)
+				}
+				return
+			}
+			if !errors.Is(got, tt.want) {
+				t.Errorf("translateOverlap = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
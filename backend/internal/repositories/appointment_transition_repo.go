@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// AppointmentTransitionRepository defines the interface for appointment status history
+type AppointmentTransitionRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, transition *models.AppointmentTransition) error
+	ListForAppointment(ctx context.Context, tx *gorm.DB, appointmentID uint) ([]*models.AppointmentTransition, error)
+}
+
+type appointmentTransitionRepo struct {
+	db *gorm.DB
+}
+
+// NewAppointmentTransitionRepository creates a new appointment transition repository
+func NewAppointmentTransitionRepository(db *gorm.DB) AppointmentTransitionRepository {
+	return &appointmentTransitionRepo{db: db}
+}
+
+// Create records a status change
+func (r *appointmentTransitionRepo) Create(ctx context.Context, tx *gorm.DB, transition *models.AppointmentTransition) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(transition).Error
+}
+
+// ListForAppointment retrieves an appointment's status changes, oldest first
+func (r *appointmentTransitionRepo) ListForAppointment(ctx context.Context, tx *gorm.DB, appointmentID uint) ([]*models.AppointmentTransition, error) {
+	var transitions []*models.AppointmentTransition
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("appointment_id = ?", appointmentID).Order("created_at, id").Find(&transitions).Error
+	return transitions, err
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *appointmentTransitionRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// AuditRepository defines the interface for audit log data access
+type AuditRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, entry *models.AuditLog) error
+}
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepo{db: db}
+}
+
+// Create appends an audit log entry
+func (r *auditRepo) Create(ctx context.Context, tx *gorm.DB, entry *models.AuditLog) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(entry).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *auditRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuestRepository defines the interface for guest data access
+type GuestRepository interface {
+	ClaimByEmail(ctx context.Context, tx *gorm.DB, email string, userID uint) (int64, error)
+	AnonymizeClaimedBy(ctx context.Context, tx *gorm.DB, userID uint, name string) error
+	CountForEmailSince(ctx context.Context, tx *gorm.DB, email string, since time.Time) (int64, error)
+	CountForIPSince(ctx context.Context, tx *gorm.DB, ip string, since time.Time) (int64, error)
+}
+
+type guestRepo struct {
+	db *gorm.DB
+}
+
+// NewGuestRepository creates a new guest repository
+func NewGuestRepository(db *gorm.DB) GuestRepository {
+	return &guestRepo{db: db}
+}
+
+// ClaimByEmail moves the appointments of every unclaimed guest with the
+// given email to the user and marks those guests as claimed. It returns the
+// number of appointments moved.
+func (r *guestRepo) ClaimByEmail(ctx context.Context, tx *gorm.DB, email string, userID uint) (int64, error) {
+	db := r.getDB(tx).WithContext(ctx)
+
+	var guestIDs []uint
+	if err := db.Model(&models.Guest{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("LOWER(email) = LOWER(?) AND claimed_at IS NULL", email).
+		Pluck("id", &guestIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(guestIDs) == 0 {
+		return 0, nil
+	}
+
+	result := db.Model(&models.Appointment{}).
+		Where("guest_id IN ? AND user_id IS NULL", guestIDs).
+		Update("user_id", userID)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	err := db.Model(&models.Guest{}).
+		Where("id IN ?", guestIDs).
+		Updates(map[string]interface{}{"claimed_by_user_id": userID, "claimed_at": time.Now()}).Error
+	return result.RowsAffected, err
+}
+
+// AnonymizeClaimedBy replaces the contact details of every guest claimed by
+// the user
+func (r *guestRepo) AnonymizeClaimedBy(ctx context.Context, tx *gorm.DB, userID uint, name string) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.Guest{}).
+		Where("claimed_by_user_id = ?", userID).
+		Updates(map[string]interface{}{"name": name, "email": "", "phone": "", "ip_address": ""}).Error
+}
+
+// CountForEmailSince counts the guests created with the email since the given time
+func (r *guestRepo) CountForEmailSince(ctx context.Context, tx *gorm.DB, email string, since time.Time) (int64, error) {
+	var count int64
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Model(&models.Guest{}).
+		Where("LOWER(email) = LOWER(?) AND created_at > ?", email, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountForIPSince counts the guests created from the address since the given time
+func (r *guestRepo) CountForIPSince(ctx context.Context, tx *gorm.DB, ip string, since time.Time) (int64, error) {
+	var count int64
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Model(&models.Guest{}).
+		Where("ip_address = ? AND created_at > ?", ip, since).
+		Count(&count).Error
+	return count, err
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *guestRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImpersonationRepository defines the interface for impersonation data access
+type ImpersonationRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, impersonation *models.Impersonation) error
+	GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Impersonation, error)
+	GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Impersonation, error)
+	Update(ctx context.Context, tx *gorm.DB, impersonation *models.Impersonation) error
+}
+
+type impersonationRepo struct {
+	db *gorm.DB
+}
+
+// NewImpersonationRepository creates a new impersonation repository
+func NewImpersonationRepository(db *gorm.DB) ImpersonationRepository {
+	return &impersonationRepo{db: db}
+}
+
+// Create stores a new impersonation
+func (r *impersonationRepo) Create(ctx context.Context, tx *gorm.DB, impersonation *models.Impersonation) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(impersonation).Error
+}
+
+// GetByID retrieves an impersonation by ID
+func (r *impersonationRepo) GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Impersonation, error) {
+	var impersonation models.Impersonation
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).First(&impersonation, id).Error
+	return &impersonation, err
+}
+
+// GetByIDForUpdate retrieves an impersonation by ID with a row lock
+func (r *impersonationRepo) GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Impersonation, error) {
+	var impersonation models.Impersonation
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&impersonation, id).Error
+	return &impersonation, err
+}
+
+// Update saves changes to an impersonation
+func (r *impersonationRepo) Update(ctx context.Context, tx *gorm.DB, impersonation *models.Impersonation) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(impersonation).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *impersonationRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvitationRepository defines the interface for invitation data access
+type InvitationRepository interface {
+	GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Invitation, error)
+	GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Invitation, error)
+	Create(ctx context.Context, tx *gorm.DB, invitation *models.Invitation) error
+	Update(ctx context.Context, tx *gorm.DB, invitation *models.Invitation) error
+	ListPending(ctx context.Context, tx *gorm.DB) ([]*models.Invitation, error)
+}
+
+type invitationRepo struct {
+	db *gorm.DB
+}
+
+// NewInvitationRepository creates a new invitation repository
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepo{db: db}
+}
+
+// GetByID retrieves an invitation by ID
+func (r *invitationRepo) GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).First(&invitation, id).Error
+	return &invitation, err
+}
+
+// GetByIDForUpdate retrieves an invitation by ID and locks the row
+func (r *invitationRepo) GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&invitation, id).Error
+	return &invitation, err
+}
+
+// Create creates a new invitation
+func (r *invitationRepo) Create(ctx context.Context, tx *gorm.DB, invitation *models.Invitation) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(invitation).Error
+}
+
+// Update updates an existing invitation
+func (r *invitationRepo) Update(ctx context.Context, tx *gorm.DB, invitation *models.Invitation) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(invitation).Error
+}
+
+// ListPending retrieves invitations that are neither accepted, revoked nor expired
+func (r *invitationRepo) ListPending(ctx context.Context, tx *gorm.DB) ([]*models.Invitation, error) {
+	var invitations []*models.Invitation
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).
+		Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *invitationRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleRepository defines the interface for failed-login tracking data access
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) (*models.LoginThrottle, error)
+	GetOrCreateForUpdate(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) (*models.LoginThrottle, error)
+	Update(ctx context.Context, tx *gorm.DB, throttle *models.LoginThrottle) error
+	Delete(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) error
+}
+
+type loginThrottleRepo struct {
+	db *gorm.DB
+}
+
+// NewLoginThrottleRepository creates a new login throttle repository
+func NewLoginThrottleRepository(db *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepo{db: db}
+}
+
+// Get retrieves the throttle record for a scope and key
+func (r *loginThrottleRepo) Get(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("scope = ? AND key = ?", scope, key).First(&throttle).Error
+	return &throttle, err
+}
+
+// GetOrCreateForUpdate retrieves the throttle record for a scope and key,
+// creating it if needed, and locks the row
+func (r *loginThrottleRepo) GetOrCreateForUpdate(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) (*models.LoginThrottle, error) {
+	db := r.getDB(tx).WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Scope: scope, Key: key}).Error; err != nil {
+		return nil, err
+	}
+
+	var throttle models.LoginThrottle
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND key = ?", scope, key).First(&throttle).Error
+	return &throttle, err
+}
+
+// Update updates an existing throttle record
+func (r *loginThrottleRepo) Update(ctx context.Context, tx *gorm.DB, throttle *models.LoginThrottle) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(throttle).Error
+}
+
+// Delete clears the throttle record for a scope and key
+func (r *loginThrottleRepo) Delete(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("scope = ? AND key = ?", scope, key).Delete(&models.LoginThrottle{}).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *loginThrottleRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
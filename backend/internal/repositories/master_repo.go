@@ -5,11 +5,14 @@ import (
 
 	"github.com/timebook/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MasterRepository defines the interface for master profile data access
 type MasterRepository interface {
 	GetByUserID(ctx context.Context, tx *gorm.DB, userID uint) (*models.MasterProfile, error)
+	GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.MasterProfile, error)
+	GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.MasterProfile, error)
 	Create(ctx context.Context, tx *gorm.DB, profile *models.MasterProfile) error
 	Update(ctx context.Context, tx *gorm.DB, profile *models.MasterProfile) error
 }
@@ -31,6 +34,23 @@ func (r *masterRepo) GetByUserID(ctx context.Context, tx *gorm.DB, userID uint)
 	return &profile, err
 }
 
+// GetByID retrieves a master profile by ID
+func (r *masterRepo) GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.MasterProfile, error) {
+	var profile models.MasterProfile
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).First(&profile, id).Error
+	return &profile, err
+}
+
+// GetByIDForUpdate retrieves a master profile by ID and locks the row, which
+// serializes changes to the master's calendar
+func (r *masterRepo) GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.MasterProfile, error) {
+	var profile models.MasterProfile
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&profile, id).Error
+	return &profile, err
+}
+
 // Create creates a new master profile
 func (r *masterRepo) Create(ctx context.Context, tx *gorm.DB, profile *models.MasterProfile) error {
 	db := r.getDB(tx)
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OIDCStateRepository defines the interface for pending OIDC login data access
+type OIDCStateRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, state *models.OIDCLoginState) error
+	GetByHashForUpdate(ctx context.Context, tx *gorm.DB, stateHash string) (*models.OIDCLoginState, error)
+	Update(ctx context.Context, tx *gorm.DB, state *models.OIDCLoginState) error
+	DeleteExpired(ctx context.Context, tx *gorm.DB, before time.Time) error
+}
+
+type oidcStateRepo struct {
+	db *gorm.DB
+}
+
+// NewOIDCStateRepository creates a new OIDC login state repository
+func NewOIDCStateRepository(db *gorm.DB) OIDCStateRepository {
+	return &oidcStateRepo{db: db}
+}
+
+// Create stores a new login state
+func (r *oidcStateRepo) Create(ctx context.Context, tx *gorm.DB, state *models.OIDCLoginState) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(state).Error
+}
+
+// GetByHashForUpdate retrieves a login state by its hash and locks the row
+func (r *oidcStateRepo) GetByHashForUpdate(ctx context.Context, tx *gorm.DB, stateHash string) (*models.OIDCLoginState, error) {
+	var state models.OIDCLoginState
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("state_hash = ?", stateHash).First(&state).Error
+	return &state, err
+}
+
+// Update updates an existing login state
+func (r *oidcStateRepo) Update(ctx context.Context, tx *gorm.DB, state *models.OIDCLoginState) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(state).Error
+}
+
+// DeleteExpired removes login states that expired before the given time
+func (r *oidcStateRepo) DeleteExpired(ctx context.Context, tx *gorm.DB, before time.Time) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.OIDCLoginState{}).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *oidcStateRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PhoneLoginCodeRepository defines the interface for phone login code data access
+type PhoneLoginCodeRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, code *models.PhoneLoginCode) error
+	GetActiveForUpdate(ctx context.Context, tx *gorm.DB, phone string, now time.Time) (*models.PhoneLoginCode, error)
+	GetLatest(ctx context.Context, tx *gorm.DB, phone string) (*models.PhoneLoginCode, error)
+	Update(ctx context.Context, tx *gorm.DB, code *models.PhoneLoginCode) error
+	InvalidateForPhone(ctx context.Context, tx *gorm.DB, phone string) error
+	CountForPhoneSince(ctx context.Context, tx *gorm.DB, phone string, since time.Time) (int64, error)
+	CountForIPSince(ctx context.Context, tx *gorm.DB, ip string, since time.Time) (int64, error)
+	DeleteCreatedBefore(ctx context.Context, tx *gorm.DB, before time.Time) error
+}
+
+type phoneLoginCodeRepo struct {
+	db *gorm.DB
+}
+
+// NewPhoneLoginCodeRepository creates a new phone login code repository
+func NewPhoneLoginCodeRepository(db *gorm.DB) PhoneLoginCodeRepository {
+	return &phoneLoginCodeRepo{db: db}
+}
+
+// Create stores a new code
+func (r *phoneLoginCodeRepo) Create(ctx context.Context, tx *gorm.DB, code *models.PhoneLoginCode) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(code).Error
+}
+
+// GetActiveForUpdate retrieves the newest unused, unexpired code for a phone
+// number and locks the row
+func (r *phoneLoginCodeRepo) GetActiveForUpdate(ctx context.Context, tx *gorm.DB, phone string, now time.Time) (*models.PhoneLoginCode, error) {
+	var code models.PhoneLoginCode
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("phone = ? AND used_at IS NULL AND expires_at > ?", phone, now).
+		Order("created_at DESC").
+		First(&code).Error
+	return &code, err
+}
+
+// GetLatest retrieves the most recently issued code for a phone number
+func (r *phoneLoginCodeRepo) GetLatest(ctx context.Context, tx *gorm.DB, phone string) (*models.PhoneLoginCode, error) {
+	var code models.PhoneLoginCode
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("phone = ?", phone).Order("created_at DESC").First(&code).Error
+	return &code, err
+}
+
+// Update saves changes to a code
+func (r *phoneLoginCodeRepo) Update(ctx context.Context, tx *gorm.DB, code *models.PhoneLoginCode) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(code).Error
+}
+
+// InvalidateForPhone marks every outstanding code for a phone number as used
+func (r *phoneLoginCodeRepo) InvalidateForPhone(ctx context.Context, tx *gorm.DB, phone string) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.PhoneLoginCode{}).
+		Where("phone = ? AND used_at IS NULL", phone).
+		Update("used_at", time.Now()).Error
+}
+
+// CountForPhoneSince counts the codes sent to a phone number since the given time
+func (r *phoneLoginCodeRepo) CountForPhoneSince(ctx context.Context, tx *gorm.DB, phone string, since time.Time) (int64, error) {
+	var count int64
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Model(&models.PhoneLoginCode{}).
+		Where("phone = ? AND created_at > ?", phone, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountForIPSince counts the codes requested from a client address since the given time
+func (r *phoneLoginCodeRepo) CountForIPSince(ctx context.Context, tx *gorm.DB, ip string, since time.Time) (int64, error) {
+	var count int64
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Model(&models.PhoneLoginCode{}).
+		Where("ip_address = ? AND created_at > ?", ip, since).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteCreatedBefore removes codes issued before the given time
+func (r *phoneLoginCodeRepo) DeleteCreatedBefore(ctx context.Context, tx *gorm.DB, before time.Time) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("created_at < ?", before).Delete(&models.PhoneLoginCode{}).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *phoneLoginCodeRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecoveryCodeRepository defines the interface for 2FA recovery code data access
+type RecoveryCodeRepository interface {
+	ReplaceForUser(ctx context.Context, tx *gorm.DB, userID uint, codes []*models.RecoveryCode) error
+	GetUnusedForUpdate(ctx context.Context, tx *gorm.DB, userID uint, codeHash string) (*models.RecoveryCode, error)
+	Update(ctx context.Context, tx *gorm.DB, code *models.RecoveryCode) error
+	DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error
+}
+
+type recoveryCodeRepo struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository creates a new recovery code repository
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepo{db: db}
+}
+
+// ReplaceForUser deletes a user's existing codes and stores new ones
+func (r *recoveryCodeRepo) ReplaceForUser(ctx context.Context, tx *gorm.DB, userID uint, codes []*models.RecoveryCode) error {
+	if err := r.DeleteForUser(ctx, tx, userID); err != nil {
+		return err
+	}
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(&codes).Error
+}
+
+// GetUnusedForUpdate retrieves an unused code by hash and locks the row
+func (r *recoveryCodeRepo) GetUnusedForUpdate(ctx context.Context, tx *gorm.DB, userID uint, codeHash string) (*models.RecoveryCode, error) {
+	var code models.RecoveryCode
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		First(&code).Error
+	return &code, err
+}
+
+// Update updates an existing code
+func (r *recoveryCodeRepo) Update(ctx context.Context, tx *gorm.DB, code *models.RecoveryCode) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(code).Error
+}
+
+// DeleteForUser removes all of a user's codes
+func (r *recoveryCodeRepo) DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *recoveryCodeRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshTokenRepository defines the interface for refresh token data access
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, token *models.RefreshToken) error
+	GetByHashForUpdate(ctx context.Context, tx *gorm.DB, tokenHash string) (*models.RefreshToken, error)
+	Update(ctx context.Context, tx *gorm.DB, token *models.RefreshToken) error
+	RevokeSession(ctx context.Context, tx *gorm.DB, sessionID string) error
+	RevokeAllForUser(ctx context.Context, tx *gorm.DB, userID uint) error
+	RevokeSessions(ctx context.Context, tx *gorm.DB, sessionIDs []string) error
+}
+
+type refreshTokenRepo struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository creates a new refresh token repository
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepo{db: db}
+}
+
+// Create stores a new refresh token
+func (r *refreshTokenRepo) Create(ctx context.Context, tx *gorm.DB, token *models.RefreshToken) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(token).Error
+}
+
+// GetByHashForUpdate retrieves a refresh token by its hash and locks the row
+// so that concurrent refreshes of the same token are serialized
+func (r *refreshTokenRepo) GetByHashForUpdate(ctx context.Context, tx *gorm.DB, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", tokenHash).First(&token).Error
+	return &token, err
+}
+
+// Update updates an existing refresh token
+func (r *refreshTokenRepo) Update(ctx context.Context, tx *gorm.DB, token *models.RefreshToken) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(token).Error
+}
+
+// RevokeSession revokes every refresh token issued for a session
+func (r *refreshTokenRepo) RevokeSession(ctx context.Context, tx *gorm.DB, sessionID string) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every refresh token belonging to a user
+func (r *refreshTokenRepo) RevokeAllForUser(ctx context.Context, tx *gorm.DB, userID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeSessions revokes every refresh token issued for the given sessions
+func (r *refreshTokenRepo) RevokeSessions(ctx context.Context, tx *gorm.DB, sessionIDs []string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("session_id IN ? AND revoked_at IS NULL", sessionIDs).
+		Update("revoked_at", time.Now()).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *refreshTokenRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
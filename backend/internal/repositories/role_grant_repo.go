@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoleGrantRepository defines the interface for role grant data access
+type RoleGrantRepository interface {
+	ListForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.RoleGrant, error)
+	Create(ctx context.Context, tx *gorm.DB, grant *models.RoleGrant) error
+	Delete(ctx context.Context, tx *gorm.DB, userID uint, role models.UserRole) (bool, error)
+	DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error
+}
+
+type roleGrantRepo struct {
+	db *gorm.DB
+}
+
+// NewRoleGrantRepository creates a new role grant repository
+func NewRoleGrantRepository(db *gorm.DB) RoleGrantRepository {
+	return &roleGrantRepo{db: db}
+}
+
+// ListForUser retrieves the roles granted to a user
+func (r *roleGrantRepo) ListForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.RoleGrant, error) {
+	var grants []*models.RoleGrant
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&grants).Error
+	return grants, err
+}
+
+// Create grants a role; granting a role the user already has is a no-op
+func (r *roleGrantRepo) Create(ctx context.Context, tx *gorm.DB, grant *models.RoleGrant) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(grant).Error
+}
+
+// Delete removes a granted role and reports whether it existed
+func (r *roleGrantRepo) Delete(ctx context.Context, tx *gorm.DB, userID uint, role models.UserRole) (bool, error) {
+	db := r.getDB(tx)
+	result := db.WithContext(ctx).Where("user_id = ? AND role = ?", userID, role).Delete(&models.RoleGrant{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteForUser removes every role granted to a user
+func (r *roleGrantRepo) DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.RoleGrant{}).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *roleGrantRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ServiceRepository defines the interface for service data access
+type ServiceRepository interface {
+	GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Service, error)
+	ListForMaster(ctx context.Context, tx *gorm.DB, masterID uint) ([]*models.Service, error)
+	DeleteForMaster(ctx context.Context, tx *gorm.DB, masterID uint) error
+}
+
+type serviceRepo struct {
+	db *gorm.DB
+}
+
+// NewServiceRepository creates a new service repository
+func NewServiceRepository(db *gorm.DB) ServiceRepository {
+	return &serviceRepo{db: db}
+}
+
+// GetByID retrieves a service by ID with its options
+func (r *serviceRepo) GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.Service, error) {
+	var service models.Service
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Preload("Options").First(&service, id).Error
+	return &service, err
+}
+
+// ListForMaster retrieves a master's services with their options
+func (r *serviceRepo) ListForMaster(ctx context.Context, tx *gorm.DB, masterID uint) ([]*models.Service, error) {
+	var services []*models.Service
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Preload("Options").Where("master_id = ?", masterID).Order("id").Find(&services).Error
+	return services, err
+}
+
+// DeleteForMaster soft-deletes all of a master's services so they can no
+// longer be booked
+func (r *serviceRepo) DeleteForMaster(ctx context.Context, tx *gorm.DB, masterID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("master_id = ?", masterID).Delete(&models.Service{}).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *serviceRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// SessionRepository defines the interface for login session data access
+type SessionRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, session *models.Session) error
+	GetBySessionID(ctx context.Context, tx *gorm.DB, sessionID string) (*models.Session, error)
+	GetByIDForUser(ctx context.Context, tx *gorm.DB, id, userID uint) (*models.Session, error)
+	ListActiveForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.Session, error)
+	Update(ctx context.Context, tx *gorm.DB, session *models.Session) error
+	TouchLastSeen(ctx context.Context, tx *gorm.DB, sessionID string, at time.Time) error
+	Revoke(ctx context.Context, tx *gorm.DB, sessionID string) error
+	RevokeAllForUser(ctx context.Context, tx *gorm.DB, userID uint, exceptSessionID string) ([]string, error)
+}
+
+type sessionRepo struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepo{db: db}
+}
+
+// Create stores a new session
+func (r *sessionRepo) Create(ctx context.Context, tx *gorm.DB, session *models.Session) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(session).Error
+}
+
+// GetBySessionID retrieves a session by the ID carried in tokens
+func (r *sessionRepo) GetBySessionID(ctx context.Context, tx *gorm.DB, sessionID string) (*models.Session, error) {
+	var session models.Session
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("session_id = ?", sessionID).First(&session).Error
+	return &session, err
+}
+
+// GetByIDForUser retrieves one of a user's sessions by ID
+func (r *sessionRepo) GetByIDForUser(ctx context.Context, tx *gorm.DB, id, userID uint) (*models.Session, error) {
+	var session models.Session
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&session).Error
+	return &session, err
+}
+
+// ListActiveForUser retrieves a user's unrevoked, unexpired sessions, most recently used first
+func (r *sessionRepo) ListActiveForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.Session, error) {
+	var sessions []*models.Session
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Update updates an existing session
+func (r *sessionRepo) Update(ctx context.Context, tx *gorm.DB, session *models.Session) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(session).Error
+}
+
+// TouchLastSeen records activity on a session without touching other columns
+func (r *sessionRepo) TouchLastSeen(ctx context.Context, tx *gorm.DB, sessionID string, at time.Time) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id = ?", sessionID).
+		UpdateColumn("last_seen_at", at).Error
+}
+
+// Revoke marks a session as revoked
+func (r *sessionRepo) Revoke(ctx context.Context, tx *gorm.DB, sessionID string) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every active session of a user except
+// exceptSessionID (which may be empty) and returns the revoked session IDs
+func (r *sessionRepo) RevokeAllForUser(ctx context.Context, tx *gorm.DB, userID uint, exceptSessionID string) ([]string, error) {
+	db := r.getDB(tx)
+
+	var sessionIDs []string
+	err := db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND session_id <> ?", userID, exceptSessionID).
+		Pluck("session_id", &sessionIDs).Error
+	if err != nil || len(sessionIDs) == 0 {
+		return sessionIDs, err
+	}
+
+	err = db.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id IN ?", sessionIDs).
+		Update("revoked_at", time.Now()).Error
+	return sessionIDs, err
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *sessionRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
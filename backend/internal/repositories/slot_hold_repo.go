@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SlotHoldRepository defines the interface for slot hold data access
+type SlotHoldRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, hold *models.SlotHold) error
+	GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.SlotHold, error)
+	Delete(ctx context.Context, tx *gorm.DB, id uint) error
+	DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error
+	DeleteExpired(ctx context.Context, tx *gorm.DB, now time.Time) (int64, error)
+	ListActiveForMasterBetween(ctx context.Context, tx *gorm.DB, masterID uint, from, to, now time.Time) ([]*models.SlotHold, error)
+}
+
+type slotHoldRepo struct {
+	db *gorm.DB
+}
+
+// NewSlotHoldRepository creates a new slot hold repository
+func NewSlotHoldRepository(db *gorm.DB) SlotHoldRepository {
+	return &slotHoldRepo{db: db}
+}
+
+// Create creates a new slot hold
+func (r *slotHoldRepo) Create(ctx context.Context, tx *gorm.DB, hold *models.SlotHold) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(hold).Error
+}
+
+// GetByIDForUpdate retrieves a slot hold by ID and locks the row
+func (r *slotHoldRepo) GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.SlotHold, error) {
+	var hold models.SlotHold
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, id).Error
+	return &hold, err
+}
+
+// Delete removes a slot hold
+func (r *slotHoldRepo) Delete(ctx context.Context, tx *gorm.DB, id uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Delete(&models.SlotHold{}, id).Error
+}
+
+// DeleteForUser removes every hold of a user
+func (r *slotHoldRepo) DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.SlotHold{}).Error
+}
+
+// DeleteExpired removes the holds that expired before now and returns how
+// many there were
+func (r *slotHoldRepo) DeleteExpired(ctx context.Context, tx *gorm.DB, now time.Time) (int64, error) {
+	db := r.getDB(tx)
+	result := db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.SlotHold{})
+	return result.RowsAffected, result.Error
+}
+
+// ListActiveForMasterBetween retrieves the master's unexpired holds
+// overlapping the given time
+func (r *slotHoldRepo) ListActiveForMasterBetween(ctx context.Context, tx *gorm.DB, masterID uint, from, to, now time.Time) ([]*models.SlotHold, error) {
+	var holds []*models.SlotHold
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where(
+		"master_id = ? AND expires_at > ? AND start_time < ? AND end_time > ?",
+		masterID, now, to, from,
+	).Order("start_time").Find(&holds).Error
+	return holds, err
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *slotHoldRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
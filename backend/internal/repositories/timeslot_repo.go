@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/timebook/backend/internal/models"
 	"gorm.io/gorm"
@@ -14,6 +15,11 @@ type TimeslotRepository interface {
 	Update(ctx context.Context, tx *gorm.DB, slot *models.TimeSlot) error
 	BookAllSlotsAtTime(ctx context.Context, tx *gorm.DB, masterID uint, startTime, endTime interface{}) error
 	EnsureSlotExists(ctx context.Context, tx *gorm.DB, appointment *models.Appointment) error
+	ReleaseAllSlotsAtTime(ctx context.Context, tx *gorm.DB, masterID uint, startTime, endTime interface{}) error
+	BookServiceSlot(ctx context.Context, tx *gorm.DB, masterID, serviceID uint, startTime, endTime time.Time) error
+	ListBookedForMasterBetween(ctx context.Context, tx *gorm.DB, masterID uint, from, to time.Time) ([]*models.TimeSlot, error)
+	ListForMaster(ctx context.Context, tx *gorm.DB, masterID uint) ([]*models.TimeSlot, error)
+	DeleteUnbookedForMaster(ctx context.Context, tx *gorm.DB, masterID uint) error
 }
 
 type timeslotRepo struct {
@@ -54,6 +60,51 @@ func (r *timeslotRepo) BookAllSlotsAtTime(ctx context.Context, tx *gorm.DB, mast
 	).Update("is_booked", true).Error
 }
 
+// ReleaseAllSlotsAtTime marks every slot of the master at the given time as free again
+func (r *timeslotRepo) ReleaseAllSlotsAtTime(ctx context.Context, tx *gorm.DB, masterID uint, startTime, endTime interface{}) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.TimeSlot{}).Where(
+		"master_id = ? AND start_time = ? AND end_time = ?",
+		masterID, startTime, endTime,
+	).Update("is_booked", false).Error
+}
+
+// BookServiceSlot marks the free slot of the service at exactly the given
+// time as booked, if there is one
+func (r *timeslotRepo) BookServiceSlot(ctx context.Context, tx *gorm.DB, masterID, serviceID uint, startTime, endTime time.Time) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.TimeSlot{}).Where(
+		"master_id = ? AND service_id = ? AND start_time = ? AND end_time = ? AND is_booked = ?",
+		masterID, serviceID, startTime, endTime, false,
+	).Update("is_booked", true).Error
+}
+
+// ListBookedForMasterBetween retrieves the master's booked slots
+// overlapping the given time
+func (r *timeslotRepo) ListBookedForMasterBetween(ctx context.Context, tx *gorm.DB, masterID uint, from, to time.Time) ([]*models.TimeSlot, error) {
+	var slots []*models.TimeSlot
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where(
+		"master_id = ? AND is_booked = ? AND start_time < ? AND end_time > ?",
+		masterID, true, to, from,
+	).Order("start_time").Find(&slots).Error
+	return slots, err
+}
+
+// ListForMaster retrieves all of a master's time slots in chronological order
+func (r *timeslotRepo) ListForMaster(ctx context.Context, tx *gorm.DB, masterID uint) ([]*models.TimeSlot, error) {
+	var slots []*models.TimeSlot
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("master_id = ?", masterID).Order("start_time").Find(&slots).Error
+	return slots, err
+}
+
+// DeleteUnbookedForMaster soft-deletes a master's free time slots
+func (r *timeslotRepo) DeleteUnbookedForMaster(ctx context.Context, tx *gorm.DB, masterID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("master_id = ? AND is_booked = ?", masterID, false).Delete(&models.TimeSlot{}).Error
+}
+
 // EnsureSlotExists creates a time slot if it doesn't exist
 func (r *timeslotRepo) EnsureSlotExists(ctx context.Context, tx *gorm.DB, appointment *models.Appointment) error {
 	var existingSlot models.TimeSlot
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// UserIdentityRepository defines the interface for external identity data access
+type UserIdentityRepository interface {
+	GetByIssuerSubject(ctx context.Context, tx *gorm.DB, issuer, subject string) (*models.UserIdentity, error)
+	Create(ctx context.Context, tx *gorm.DB, identity *models.UserIdentity) error
+	Update(ctx context.Context, tx *gorm.DB, identity *models.UserIdentity) error
+	ListForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.UserIdentity, error)
+	DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error
+}
+
+type userIdentityRepo struct {
+	db *gorm.DB
+}
+
+// NewUserIdentityRepository creates a new user identity repository
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &userIdentityRepo{db: db}
+}
+
+// GetByIssuerSubject retrieves the identity for a provider account
+func (r *userIdentityRepo) GetByIssuerSubject(ctx context.Context, tx *gorm.DB, issuer, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	return &identity, err
+}
+
+// Create links a new identity to a user
+func (r *userIdentityRepo) Create(ctx context.Context, tx *gorm.DB, identity *models.UserIdentity) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(identity).Error
+}
+
+// Update updates an existing identity
+func (r *userIdentityRepo) Update(ctx context.Context, tx *gorm.DB, identity *models.UserIdentity) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(identity).Error
+}
+
+// ListForUser retrieves the provider accounts linked to a user
+func (r *userIdentityRepo) ListForUser(ctx context.Context, tx *gorm.DB, userID uint) ([]*models.UserIdentity, error) {
+	var identities []*models.UserIdentity
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// DeleteForUser unlinks every provider account from a user
+func (r *userIdentityRepo) DeleteForUser(ctx context.Context, tx *gorm.DB, userID uint) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.UserIdentity{}).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *userIdentityRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
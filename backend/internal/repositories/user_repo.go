@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// User statuses accepted by UserFilter
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusDeleted   = "deleted"
+)
+
+// UserFilter narrows a user search. Empty fields match everything; without
+// a status, deleted users are left out.
+type UserFilter struct {
+	Query  string
+	Role   models.UserRole
+	Status string
+	Offset int
+	Limit  int
+}
+
+// UserRepository defines the interface for user data access
+type UserRepository interface {
+	GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error)
+	GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error)
+	GetByIDUnscoped(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error)
+	GetByIDUnscopedForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error)
+	Search(ctx context.Context, tx *gorm.DB, filter UserFilter) ([]*models.User, int64, error)
+	GetByEmail(ctx context.Context, tx *gorm.DB, email string) (*models.User, error)
+	ExistsByEmailFold(ctx context.Context, tx *gorm.DB, email string) (bool, error)
+	ListByPhone(ctx context.Context, tx *gorm.DB, phone string) ([]*models.User, error)
+	Create(ctx context.Context, tx *gorm.DB, user *models.User) error
+	Update(ctx context.Context, tx *gorm.DB, user *models.User) error
+	Delete(ctx context.Context, tx *gorm.DB, user *models.User) error
+	Restore(ctx context.Context, tx *gorm.DB, user *models.User) error
+}
+
+type userRepo struct {
+	db *gorm.DB
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepo{db: db}
+}
+
+// GetByID retrieves a user by ID
+func (r *userRepo) GetByID(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error) {
+	var user models.User
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Preload("RoleGrants").First(&user, id).Error
+	return &user, err
+}
+
+// GetByIDForUpdate retrieves a user by ID and locks the row
+func (r *userRepo) GetByIDForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error) {
+	var user models.User
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Preload("RoleGrants").First(&user, id).Error
+	return &user, err
+}
+
+// GetByIDUnscoped retrieves a user by ID, including soft-deleted users
+func (r *userRepo) GetByIDUnscoped(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error) {
+	var user models.User
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Unscoped().Preload("RoleGrants").First(&user, id).Error
+	return &user, err
+}
+
+// GetByIDUnscopedForUpdate retrieves a user by ID, including soft-deleted
+// users, and locks the row
+func (r *userRepo) GetByIDUnscopedForUpdate(ctx context.Context, tx *gorm.DB, id uint) (*models.User, error) {
+	var user models.User
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Preload("RoleGrants").First(&user, id).Error
+	return &user, err
+}
+
+// Search retrieves a page of users matching the filter, ordered by ID, and
+// the total number of matches
+func (r *userRepo) Search(ctx context.Context, tx *gorm.DB, filter UserFilter) ([]*models.User, int64, error) {
+	db := r.getDB(tx).WithContext(ctx).Model(&models.User{})
+
+	switch filter.Status {
+	case UserStatusActive:
+		db = db.Where("suspended_at IS NULL")
+	case UserStatusSuspended:
+		db = db.Where("suspended_at IS NOT NULL")
+	case UserStatusDeleted:
+		db = db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		pattern := "%" + q + "%"
+		db = db.Where("name ILIKE ? OR email ILIKE ? OR phone LIKE ?", pattern, pattern, pattern)
+	}
+	if filter.Role != "" {
+		db = db.Scopes(UserHasRole(filter.Role))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []*models.User
+	err := db.Preload("RoleGrants").
+		Order("id").
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		Find(&users).Error
+	return users, total, err
+}
+
+// GetByEmail retrieves a user by email address
+func (r *userRepo) GetByEmail(ctx context.Context, tx *gorm.DB, email string) (*models.User, error) {
+	var user models.User
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Preload("RoleGrants").Where("email = ?", email).First(&user).Error
+	return &user, err
+}
+
+// ExistsByEmailFold reports whether a user has the email address, ignoring case
+func (r *userRepo) ExistsByEmailFold(ctx context.Context, tx *gorm.DB, email string) (bool, error) {
+	var count int64
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Model(&models.User{}).Where("LOWER(email) = LOWER(?)", email).Count(&count).Error
+	return count > 0, err
+}
+
+// ListByPhone retrieves the users with the given phone number
+func (r *userRepo) ListByPhone(ctx context.Context, tx *gorm.DB, phone string) ([]*models.User, error) {
+	var users []*models.User
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Preload("RoleGrants").Where("phone = ?", phone).Find(&users).Error
+	return users, err
+}
+
+// Create creates a new user
+func (r *userRepo) Create(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(user).Error
+}
+
+// Update updates an existing user. Associations such as role grants are
+// managed through their own repositories and are not saved here.
+func (r *userRepo) Update(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Omit(clause.Associations).Save(user).Error
+}
+
+// Delete soft-deletes a user
+func (r *userRepo) Delete(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Delete(user).Error
+}
+
+// Restore undoes a soft delete
+func (r *userRepo) Restore(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	db := r.getDB(tx)
+	if err := db.WithContext(ctx).Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+// UserHasRole scopes a users query to users holding the role, either as
+// their primary role or through a grant
+func UserHasRole(role models.UserRole) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.role = ? OR EXISTS (SELECT 1 FROM role_grants WHERE role_grants.user_id = users.id AND role_grants.role = ?)", role, role)
+	}
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *userRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
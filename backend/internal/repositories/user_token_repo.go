@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserTokenRepository defines the interface for single-use user token data access
+type UserTokenRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, token *models.UserToken) error
+	GetByHashForUpdate(ctx context.Context, tx *gorm.DB, purpose models.TokenPurpose, tokenHash string) (*models.UserToken, error)
+	Update(ctx context.Context, tx *gorm.DB, token *models.UserToken) error
+	InvalidateForUser(ctx context.Context, tx *gorm.DB, userID uint, purpose models.TokenPurpose) error
+}
+
+type userTokenRepo struct {
+	db *gorm.DB
+}
+
+// NewUserTokenRepository creates a new user token repository
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepo{db: db}
+}
+
+// Create stores a new token
+func (r *userTokenRepo) Create(ctx context.Context, tx *gorm.DB, token *models.UserToken) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Create(token).Error
+}
+
+// GetByHashForUpdate retrieves a token of the given purpose by its hash and locks the row
+func (r *userTokenRepo) GetByHashForUpdate(ctx context.Context, tx *gorm.DB, purpose models.TokenPurpose, tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	db := r.getDB(tx)
+	err := db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("purpose = ? AND token_hash = ?", purpose, tokenHash).First(&token).Error
+	return &token, err
+}
+
+// Update updates an existing token
+func (r *userTokenRepo) Update(ctx context.Context, tx *gorm.DB, token *models.UserToken) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Save(token).Error
+}
+
+// InvalidateForUser marks all of a user's unused tokens of the given purpose as used
+func (r *userTokenRepo) InvalidateForUser(ctx context.Context, tx *gorm.DB, userID uint, purpose models.TokenPurpose) error {
+	db := r.getDB(tx)
+	return db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// getDB returns the transaction if provided, otherwise returns the default DB
+func (r *userTokenRepo) getDB(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return r.db
+}
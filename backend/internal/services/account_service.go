@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/notify"
+	"github.com/timebook/backend/internal/password"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// Account recovery errors
+var (
+	ErrInvalidResetToken = apperrors.New("INVALID_RESET_TOKEN", "Invalid or expired reset token", http.StatusBadRequest)
+	ErrIncorrectPassword = apperrors.New("INCORRECT_PASSWORD", "Current password is incorrect", http.StatusBadRequest)
+
+	ErrInvalidVerificationToken = apperrors.New("INVALID_VERIFICATION_TOKEN", "Invalid or expired verification token", http.StatusBadRequest)
+	ErrAlreadyVerified          = apperrors.New("ALREADY_VERIFIED", "Email is already verified", http.StatusConflict)
+)
+
+// Profile errors
+var (
+	ErrInvalidName             = apperrors.New("VALIDATION_ERROR", "Name must be between 1 and 255 characters", http.StatusBadRequest)
+	ErrInvalidEmail            = apperrors.New("INVALID_EMAIL", "Enter a valid email address", http.StatusBadRequest)
+	ErrInvalidEmailChangeToken = apperrors.New("INVALID_EMAIL_CHANGE_TOKEN", "Invalid or expired email change link", http.StatusBadRequest)
+)
+
+// ProfileUpdate holds the profile fields a user wants to change; nil fields
+// are left as they are. Changing the email requires the current password.
+type ProfileUpdate struct {
+	Name            *string
+	Phone           *string
+	Email           *string
+	CurrentPassword string
+}
+
+// AccountService handles self-service account operations such as password
+// recovery and email verification
+type AccountService struct {
+	userRepo         repositories.UserRepository
+	userTokenRepo    repositories.UserTokenRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	sessionRepo      repositories.SessionRepository
+	guestRepo        repositories.GuestRepository
+	auditRepo        repositories.AuditRepository
+	passwords        *password.Policy
+	notifier         notify.Notifier
+	txManager        *transaction.Manager
+	config           *config.Config
+}
+
+// NewAccountService creates a new account service
+func NewAccountService(
+	userRepo repositories.UserRepository,
+	userTokenRepo repositories.UserTokenRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	sessionRepo repositories.SessionRepository,
+	guestRepo repositories.GuestRepository,
+	auditRepo repositories.AuditRepository,
+	passwords *password.Policy,
+	notifier notify.Notifier,
+	txManager *transaction.Manager,
+	cfg *config.Config,
+) *AccountService {
+	return &AccountService{
+		userRepo:         userRepo,
+		userTokenRepo:    userTokenRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		guestRepo:        guestRepo,
+		auditRepo:        auditRepo,
+		passwords:        passwords,
+		notifier:         notifier,
+		txManager:        txManager,
+		config:           cfg,
+	}
+}
+
+// RequestPasswordReset sends a reset link to the account with the given
+// email. It succeeds silently for unknown emails so callers cannot probe
+// which addresses are registered.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, nil, strings.TrimSpace(email))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	raw, err := s.issueUserToken(ctx, user.ID, models.TokenPurposePasswordReset, s.config.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.config.FrontendURL, url.QueryEscape(raw))
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Reset your Timebook password",
+		Body: fmt.Sprintf("Use the link below to choose a new password. It expires in %s.\n\n%s\n\nIf you did not request this, you can ignore this message.",
+			s.config.PasswordResetTTL, link),
+	}
+	if err := s.notifier.Send(ctx, msg); err != nil {
+		// Do not reveal delivery failures to the caller
+		log.Printf("password reset: failed to notify user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword consumes a reset token and sets a new password. All of the
+// user's sessions are revoked so a compromised device is signed out.
+func (s *AccountService) ResetPassword(ctx context.Context, rawToken, newPassword string) error {
+	if err := s.passwords.Validate(newPassword); err != nil {
+		return err
+	}
+
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		token, err := s.userTokenRepo.GetByHashForUpdate(ctx, tx, models.TokenPurposePasswordReset, hashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidResetToken
+			}
+			return nil, err
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return nil, ErrInvalidResetToken
+		}
+
+		user, err := s.userRepo.GetByID(ctx, tx, token.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidResetToken
+			}
+			return nil, err
+		}
+
+		hashedPassword, err := s.passwords.Hash(newPassword)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = hashedPassword
+		user.PasswordUnset = false
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		token.UsedAt = &now
+		if err := s.userTokenRepo.Update(ctx, tx, token); err != nil {
+			return nil, err
+		}
+		if err := s.userTokenRepo.InvalidateForUser(ctx, tx, user.ID, models.TokenPurposePasswordReset); err != nil {
+			return nil, err
+		}
+
+		// Sign out every device, including any a thief may be using
+		if _, err := revokeUserSessions(ctx, tx, s.sessionRepo, s.refreshTokenRepo, user.ID, ""); err != nil {
+			return nil, err
+		}
+		return nil, s.refreshTokenRepo.RevokeAllForUser(ctx, tx, user.ID)
+	})
+	return err
+}
+
+// ChangePassword replaces the user's password after checking the current
+// one. Every other session is signed out; the session making the change
+// stays logged in.
+func (s *AccountService) ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword, currentSessionID string) error {
+	if err := s.passwords.Validate(newPassword); err != nil {
+		return err
+	}
+
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.userRepo.GetByIDForUpdate(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if !s.passwords.Compare(user.Password, currentPassword) {
+			return nil, ErrIncorrectPassword
+		}
+
+		hashedPassword, err := s.passwords.Hash(newPassword)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = hashedPassword
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		if err := s.userTokenRepo.InvalidateForUser(ctx, tx, user.ID, models.TokenPurposePasswordReset); err != nil {
+			return nil, err
+		}
+		_, err = revokeUserSessions(ctx, tx, s.sessionRepo, s.refreshTokenRepo, user.ID, currentSessionID)
+		return nil, err
+	})
+	return err
+}
+
+// UpgradePasswordHash rehashes the user's password at the current cost if it
+// was hashed at a lower one. It must only be called with a password that has
+// just been verified.
+func (s *AccountService) UpgradePasswordHash(ctx context.Context, user *models.User, plaintext string) error {
+	if !s.passwords.NeedsRehash(user.Password) {
+		return nil
+	}
+
+	hashedPassword, err := s.passwords.Hash(plaintext)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	return s.userRepo.Update(ctx, nil, user)
+}
+
+// SendVerification emails the user a link confirming they own their address
+func (s *AccountService) SendVerification(ctx context.Context, user *models.User) error {
+	if user.VerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+
+	raw, err := s.issueUserToken(ctx, user.ID, models.TokenPurposeEmailVerification, s.config.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.config.FrontendURL, url.QueryEscape(raw))
+	return s.notifier.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Confirm your Timebook email address",
+		Body:    fmt.Sprintf("Please confirm your email address by opening the link below. It expires in %s.\n\n%s", s.config.VerificationTTL, link),
+	})
+}
+
+// ResendVerification sends a new verification link to an authenticated user
+func (s *AccountService) ResendVerification(ctx context.Context, userID uint) error {
+	user, err := s.userRepo.GetByID(ctx, nil, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound
+		}
+		return err
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// VerifyEmail consumes a verification token and marks the user as verified.
+// Guest bookings made with the address are moved to the account, since the
+// user has now proven they own it.
+func (s *AccountService) VerifyEmail(ctx context.Context, rawToken string) (*models.User, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		token, err := s.userTokenRepo.GetByHashForUpdate(ctx, tx, models.TokenPurposeEmailVerification, hashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidVerificationToken
+			}
+			return nil, err
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return nil, ErrInvalidVerificationToken
+		}
+
+		user, err := s.userRepo.GetByID(ctx, tx, token.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidVerificationToken
+			}
+			return nil, err
+		}
+
+		now := time.Now()
+		token.UsedAt = &now
+		if err := s.userTokenRepo.Update(ctx, tx, token); err != nil {
+			return nil, err
+		}
+
+		if user.VerifiedAt == nil {
+			user.VerifiedAt = &now
+			if err := s.userRepo.Update(ctx, tx, user); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := s.guestRepo.ClaimByEmail(ctx, tx, user.Email, user.ID); err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
+
+// UpdateProfile applies changes to the user's name, phone number and email.
+// A new email address is not used until the user confirms it from a link
+// sent there; until then it is kept as the pending email. Setting the email
+// back to the current address cancels a pending change. A phone number
+// already on another account is refused.
+func (s *AccountService) UpdateProfile(ctx context.Context, userID uint, update ProfileUpdate) (*models.User, error) {
+	var name, phone, email string
+	if update.Name != nil {
+		name = strings.TrimSpace(*update.Name)
+		if name == "" || len(name) > 255 {
+			return nil, ErrInvalidName
+		}
+	}
+	if update.Phone != nil {
+		var err error
+		if phone, err = normalizePhone(*update.Phone, s.config.PhoneDefaultCountryCode); err != nil {
+			return nil, err
+		}
+	}
+	if update.Email != nil {
+		email = strings.TrimSpace(*update.Email)
+		if !validEmail(email) {
+			return nil, ErrInvalidEmail
+		}
+	}
+
+	emailChanged := false
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.userRepo.GetByIDForUpdate(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+
+		if update.Name != nil {
+			user.Name = name
+		}
+		if update.Phone != nil && phone != user.Phone {
+			if err := checkPhoneFree(ctx, tx, s.userRepo, phone, user.ID); err != nil {
+				return nil, err
+			}
+			user.Phone = phone
+		}
+		if update.Email != nil {
+			switch {
+			case strings.EqualFold(email, user.Email):
+				user.PendingEmail = ""
+			case strings.EqualFold(email, user.PendingEmail):
+				// Already waiting for confirmation; send a fresh link
+				emailChanged = true
+			default:
+				if !s.passwords.Compare(user.Password, update.CurrentPassword) {
+					return nil, ErrIncorrectPassword
+				}
+				if _, err := s.userRepo.GetByEmail(ctx, tx, email); err == nil {
+					return nil, ErrEmailTaken
+				} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, err
+				}
+				user.PendingEmail = email
+				emailChanged = true
+			}
+		}
+
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	user := result.(*models.User)
+
+	if emailChanged {
+		if err := s.sendEmailChange(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// ConfirmEmailChange consumes an email change token and makes the pending
+// email the user's address. The previous address is told about the change
+// so the owner notices if someone else made it.
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, rawToken, ip string) (*models.User, error) {
+	var previousEmail string
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		token, err := s.userTokenRepo.GetByHashForUpdate(ctx, tx, models.TokenPurposeEmailChange, hashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidEmailChangeToken
+			}
+			return nil, err
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return nil, ErrInvalidEmailChangeToken
+		}
+
+		user, err := s.userRepo.GetByIDForUpdate(ctx, tx, token.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidEmailChangeToken
+			}
+			return nil, err
+		}
+		if user.PendingEmail == "" {
+			return nil, ErrInvalidEmailChangeToken
+		}
+		if _, err := s.userRepo.GetByEmail(ctx, tx, user.PendingEmail); err == nil {
+			return nil, ErrEmailTaken
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		now := time.Now()
+		token.UsedAt = &now
+		if err := s.userTokenRepo.Update(ctx, tx, token); err != nil {
+			return nil, err
+		}
+
+		previousEmail = user.Email
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+		user.VerifiedAt = &now
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		// Links sent to the old address must no longer work
+		for _, purpose := range []models.TokenPurpose{models.TokenPurposePasswordReset, models.TokenPurposeEmailVerification} {
+			if err := s.userTokenRepo.InvalidateForUser(ctx, tx, user.ID, purpose); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := s.guestRepo.ClaimByEmail(ctx, tx, user.Email, user.ID); err != nil {
+			return nil, err
+		}
+
+		return user, s.auditRepo.Create(ctx, tx, &models.AuditLog{
+			ActorID:    &user.ID,
+			Action:     models.AuditAccountEmailChanged,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+			IPAddress:  ip,
+			Details:    fmt.Sprintf("from=%s to=%s", previousEmail, user.Email),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	user := result.(*models.User)
+
+	msg := notify.Message{
+		To:      previousEmail,
+		Subject: "Your Timebook email address was changed",
+		Body: fmt.Sprintf("The email address of your Timebook account was changed to %s. If you did not make this change, reset your password and contact support.",
+			user.Email),
+	}
+	if err := s.notifier.Send(ctx, msg); err != nil {
+		log.Printf("email change: failed to notify user %d: %v", user.ID, err)
+	}
+
+	return user, nil
+}
+
+// sendEmailChange emails a confirmation link to the user's pending email
+func (s *AccountService) sendEmailChange(ctx context.Context, user *models.User) error {
+	raw, err := s.issueUserToken(ctx, user.ID, models.TokenPurposeEmailChange, s.config.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/confirm-email?token=%s", s.config.FrontendURL, url.QueryEscape(raw))
+	return s.notifier.Send(ctx, notify.Message{
+		To:      user.PendingEmail,
+		Subject: "Confirm your new Timebook email address",
+		Body:    fmt.Sprintf("Please confirm your new email address by opening the link below. It expires in %s.\n\n%s", s.config.VerificationTTL, link),
+	})
+}
+
+// issueUserToken invalidates the user's outstanding tokens of the given
+// purpose and stores a fresh one, returning its raw value
+func (s *AccountService) issueUserToken(ctx context.Context, userID uint, purpose models.TokenPurpose, ttl time.Duration) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		if err := s.userTokenRepo.InvalidateForUser(ctx, tx, userID, purpose); err != nil {
+			return nil, err
+		}
+		return nil, s.userTokenRepo.Create(ctx, tx, &models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// validEmail reports whether email is a bare email address
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
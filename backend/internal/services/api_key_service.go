@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/middleware"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often a key's last-used timestamp is written
+const lastUsedResolution = time.Minute
+
+// API key errors
+var (
+	ErrInvalidAPIKey     = apperrors.New("INVALID_API_KEY", "Invalid or revoked API key", http.StatusUnauthorized)
+	ErrInvalidScope      = apperrors.New("INVALID_SCOPE", "Unknown API key scope", http.StatusBadRequest)
+	ErrScopeRequired     = apperrors.New("VALIDATION_ERROR", "At least one scope is required", http.StatusBadRequest)
+	ErrAPIKeyNameMissing = apperrors.New("VALIDATION_ERROR", "Name is required", http.StatusBadRequest)
+)
+
+// CreateAPIKeyInput holds the attributes of a new API key
+type CreateAPIKeyInput struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// APIKeyService manages masters' personal API keys
+type APIKeyService struct {
+	apiKeyRepo repositories.APIKeyRepository
+	userRepo   repositories.UserRepository
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(apiKeyRepo repositories.APIKeyRepository, userRepo repositories.UserRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// CreateAPIKey creates a key for the user and returns it with the raw key,
+// which is never retrievable again
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID uint, input CreateAPIKeyInput) (*models.APIKey, string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, "", ErrAPIKeyNameMissing
+	}
+	scopes, err := validateScopes(input.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", apperrors.New("VALIDATION_ERROR", "Expiry must be in the future", http.StatusBadRequest)
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	prefix, err := newRandomID()
+	if err != nil {
+		return nil, "", err
+	}
+	prefix = prefix[:8]
+	raw := middleware.APIKeyPrefix + prefix + "_" + secret
+
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(raw),
+		Scopes:    scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := s.apiKeyRepo.Create(ctx, nil, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, raw, nil
+}
+
+// ListAPIKeys returns all of the user's keys, including revoked ones
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID uint) ([]*models.APIKey, error) {
+	return s.apiKeyRepo.ListForUser(ctx, nil, userID)
+}
+
+// RevokeAPIKey permanently disables one of the user's keys
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, keyID uint) error {
+	key, err := s.apiKeyRepo.GetByIDForUser(ctx, nil, keyID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound
+		}
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	return s.apiKeyRepo.Update(ctx, nil, key)
+}
+
+// AuthenticateAPIKey implements middleware.APIKeyAuthenticator
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, rawKey string) (*middleware.Claims, []string, error) {
+	key, err := s.apiKeyRepo.GetByHash(ctx, nil, hashToken(rawKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	// Keys only work while their owner is still a master
+	user, err := s.userRepo.GetByID(ctx, nil, key.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if !user.HasRole(models.RoleMaster) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if user.Suspended() {
+		return nil, nil, ErrAccountSuspended
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.apiKeyRepo.TouchLastUsed(ctx, nil, key.ID, now); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Keys act with master permissions only, whatever other roles the owner holds
+	return &middleware.Claims{
+		UserID: user.ID,
+		Email:  user.Email,
+		Role:   string(user.Role),
+		Roles:  []string{string(models.RoleMaster)},
+	}, key.Scopes, nil
+}
+
+// validateScopes checks scopes against models.APIKeyScopes and removes duplicates
+func validateScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, ErrScopeRequired
+	}
+
+	seen := map[string]bool{}
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		valid := false
+		for _, known := range models.APIKeyScopes {
+			if scope == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
@@ -2,45 +2,304 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
 	"github.com/timebook/backend/internal/models"
 	"github.com/timebook/backend/internal/repositories"
 	"github.com/timebook/backend/internal/transaction"
 	"gorm.io/gorm"
 )
 
+// maxCancellationReasonLength limits the optional reason given when cancelling
+const maxCancellationReasonLength = 500
+
+// Cancellation errors
+var (
+	ErrAppointmentNotCancellable = apperrors.New("APPOINTMENT_NOT_CANCELLABLE", "Only upcoming pending or confirmed appointments can be cancelled", http.StatusConflict)
+	ErrCancellationReason        = apperrors.New("VALIDATION_ERROR", "Cancellation reason must be at most 500 characters", http.StatusBadRequest)
+	ErrRejectionReason           = apperrors.New("VALIDATION_ERROR", "Rejection reason must be at most 500 characters", http.StatusBadRequest)
+)
+
+// Attendance errors
+var (
+	ErrAttendanceStatus   = apperrors.New("VALIDATION_ERROR", "Attendance must be completed or no_show", http.StatusBadRequest)
+	ErrAttendanceTooEarly = apperrors.New("APPOINTMENT_NOT_STARTED", "Attendance can only be recorded once the appointment has started", http.StatusConflict)
+	ErrAttendanceReason   = apperrors.New("VALIDATION_ERROR", "Reason must be at most 500 characters", http.StatusBadRequest)
+)
+
+// autoCompletedReason is recorded when the background job completes an appointment
+const autoCompletedReason = "Completed automatically after the appointment ended"
+
+// autoCompleteBatchSize limits how many appointments one run of the
+// auto-complete job handles
+const autoCompleteBatchSize = 100
+
+// clientRescheduledReason is recorded when a client's move sends a confirmed
+// appointment back to pending
+const clientRescheduledReason = "Rescheduled by client"
+
+// Booking errors
+var (
+	ErrServiceNotFound       = apperrors.New("NOT_FOUND", "Service not found", http.StatusNotFound)
+	ErrServiceOptionRequired = apperrors.New("VALIDATION_ERROR", "This service has sub-categories. Please select one.", http.StatusBadRequest)
+	ErrServiceOptionNotFound = apperrors.New("NOT_FOUND", "Service sub-category not found", http.StatusNotFound)
+	ErrBookingClientNotFound = apperrors.New("NOT_FOUND", "Client not found", http.StatusNotFound)
+	ErrEmailNotVerified      = apperrors.New("EMAIL_NOT_VERIFIED", "Please verify your email address before booking", http.StatusForbidden)
+)
+
+// Booking is a request to book one of a master's services
+type Booking struct {
+	ServiceID       uint
+	ServiceOptionID *uint
+	StartTime       time.Time
+	Notes           string
+
+	// HoldID books a time the client is holding; the hold is used up
+	HoldID *uint
+}
+
+// Reschedule errors
+var (
+	ErrAppointmentNotReschedulable = apperrors.New("APPOINTMENT_NOT_RESCHEDULABLE", "Only upcoming pending or confirmed appointments can be rescheduled", http.StatusConflict)
+)
+
 // AppointmentService handles business logic for appointment operations
 type AppointmentService struct {
 	appointmentRepo repositories.AppointmentRepository
 	timeslotRepo    repositories.TimeslotRepository
+	masterRepo      repositories.MasterRepository
+	serviceRepo     repositories.ServiceRepository
+	userRepo        repositories.UserRepository
+	transitionRepo  repositories.AppointmentTransitionRepository
+	holdRepo        repositories.SlotHoldRepository
+	availability    *AvailabilityService
 	txManager       *transaction.Manager
+	config          *config.Config
 }
 
 // NewAppointmentService creates a new appointment service
 func NewAppointmentService(
 	appointmentRepo repositories.AppointmentRepository,
 	timeslotRepo repositories.TimeslotRepository,
+	masterRepo repositories.MasterRepository,
+	serviceRepo repositories.ServiceRepository,
+	userRepo repositories.UserRepository,
+	transitionRepo repositories.AppointmentTransitionRepository,
+	holdRepo repositories.SlotHoldRepository,
+	availability *AvailabilityService,
 	txManager *transaction.Manager,
+	cfg *config.Config,
 ) *AppointmentService {
 	return &AppointmentService{
 		appointmentRepo: appointmentRepo,
 		timeslotRepo:    timeslotRepo,
+		masterRepo:      masterRepo,
+		serviceRepo:     serviceRepo,
+		userRepo:        userRepo,
+		transitionRepo:  transitionRepo,
+		holdRepo:        holdRepo,
+		availability:    availability,
 		txManager:       txManager,
+		config:          cfg,
 	}
 }
 
-// ConfirmAppointment confirms an appointment within a transaction
-func (s *AppointmentService) ConfirmAppointment(ctx context.Context, appointmentID uint) (*models.Appointment, error) {
+// Book creates a pending appointment for the client set on appointment,
+// either a user or a new guest, and marks the matching time slot as booked.
+// When the configuration requires verified emails, users must have verified
+// theirs and guest bookings stay unconfirmed until the guest confirms theirs.
+func (s *AppointmentService) Book(ctx context.Context, booking Booking, appointment models.Appointment) (*models.Appointment, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		if appointment.UserID != nil {
+			if err := checkEmailVerified(ctx, tx, s.userRepo, s.config, *appointment.UserID); err != nil {
+				return nil, err
+			}
+		}
+
+		service, err := s.serviceRepo.GetByID(ctx, tx, booking.ServiceID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrServiceNotFound
+			}
+			return nil, err
+		}
+
+		appointment.Status = models.StatusPending
+		if appointment.Guest != nil && s.config.RequireVerifiedEmail {
+			appointment.Status = models.StatusUnconfirmed
+		}
+		return s.insertBooking(ctx, tx, service, booking, &appointment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Appointment), nil
+}
+
+// BookForClient creates a confirmed appointment that a master booked on
+// behalf of one of their clients
+func (s *AppointmentService) BookForClient(ctx context.Context, masterUserID, clientID uint, booking Booking) (*models.Appointment, error) {
+	profile, err := s.masterRepo.GetByUserID(ctx, nil, masterUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		service, err := s.serviceRepo.GetByID(ctx, tx, booking.ServiceID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrServiceNotFound
+			}
+			return nil, err
+		}
+		if service.MasterID != profile.ID {
+			return nil, ErrServiceNotFound
+		}
+
+		client, err := s.userRepo.GetByID(ctx, tx, clientID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrBookingClientNotFound
+			}
+			return nil, err
+		}
+		if !client.HasRole(models.RoleUser) || client.AnonymizedAt != nil {
+			return nil, ErrBookingClientNotFound
+		}
+
+		// Master-created appointments are auto-confirmed
+		appointment := models.Appointment{UserID: &client.ID, Status: models.StatusConfirmed}
+		return s.insertBooking(ctx, tx, service, booking, &appointment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Appointment), nil
+}
+
+// insertBooking saves a new appointment for the service at the requested
+// time, after checking with the availability service that the master is
+// free then. The master row stays locked until the transaction ends so
+// concurrent bookings are checked one after another; the
+// appointments_no_overlap constraint backs this up.
+func (s *AppointmentService) insertBooking(
+	ctx context.Context,
+	tx *gorm.DB,
+	service *models.Service,
+	booking Booking,
+	appointment *models.Appointment,
+) (*models.Appointment, error) {
+	duration, err := serviceDuration(service, booking.ServiceOptionID)
+	if err != nil {
+		return nil, err
+	}
+	startTime := booking.StartTime
+	endTime := startTime.Add(duration)
+
+	master, err := s.masterRepo.GetByIDForUpdate(ctx, tx, service.MasterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A hold the client took earlier keeps the time free for them
+	var ignore Ignore
+	if booking.HoldID != nil {
+		hold, err := s.holdRepo.GetByIDForUpdate(ctx, tx, *booking.HoldID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrHoldNotFound
+			}
+			return nil, err
+		}
+		if err := checkHoldMatches(hold, appointment, service, booking); err != nil {
+			return nil, err
+		}
+		ignore.HoldID = hold.ID
+	}
+
+	if err := s.availability.CheckFree(ctx, tx, master, startTime, endTime, ignore); err != nil {
+		return nil, err
+	}
+
+	// Unconfirmed bookings leave the time free until the guest confirms
+	if appointment.Status != models.StatusUnconfirmed {
+		if err := s.timeslotRepo.BookServiceSlot(ctx, tx, service.MasterID, service.ID, startTime, endTime); err != nil {
+			return nil, err
+		}
+	}
+
+	appointment.MasterID = service.MasterID
+	appointment.ServiceID = service.ID
+	appointment.ServiceOptionID = booking.ServiceOptionID
+	appointment.StartTime = startTime
+	appointment.EndTime = endTime
+	appointment.Notes = booking.Notes
+	if err := s.appointmentRepo.Create(ctx, tx, appointment); err != nil {
+		if errors.Is(err, repositories.ErrAppointmentOverlap) {
+			return nil, ErrTimeSlotConflict
+		}
+		return nil, err
+	}
+	if ignore.HoldID != 0 {
+		if err := s.holdRepo.Delete(ctx, tx, ignore.HoldID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.appointmentRepo.GetByID(ctx, tx, appointment.ID)
+}
+
+// serviceDuration returns how long an appointment for the service lasts. If
+// the service has sub-categories (options), a selection is required.
+func serviceDuration(service *models.Service, optionID *uint) (time.Duration, error) {
+	if len(service.Options) == 0 {
+		return time.Duration(service.Duration) * time.Minute, nil
+	}
+	if optionID == nil {
+		return 0, ErrServiceOptionRequired
+	}
+	option := findServiceOption(service, *optionID)
+	if option == nil {
+		return 0, ErrServiceOptionNotFound
+	}
+	return time.Duration(option.Duration) * time.Minute, nil
+}
+
+// findServiceOption returns the service's option with the given ID, or nil
+func findServiceOption(service *models.Service, optionID uint) *models.ServiceOption {
+	for i := range service.Options {
+		if service.Options[i].ID == optionID {
+			return &service.Options[i]
+		}
+	}
+	return nil
+}
+
+// ConfirmAppointment confirms a pending appointment within a transaction,
+// blocking its time in the master's calendar
+func (s *AppointmentService) ConfirmAppointment(ctx context.Context, actorID, appointmentID uint) (*models.Appointment, error) {
 	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
 		// Get the appointment
-		appointment, err := s.appointmentRepo.GetByID(ctx, tx, appointmentID)
+		appointment, err := s.appointmentRepo.GetByIDForUpdate(ctx, tx, appointmentID)
 		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
 			return nil, err
 		}
 
 		// Update status to confirmed
-		appointment.Status = models.StatusConfirmed
-		if err := s.appointmentRepo.Update(ctx, tx, appointment); err != nil {
+		if err := transitionAppointment(ctx, tx, s.appointmentRepo, s.transitionRepo, appointment, models.StatusConfirmed, &actorID, ""); err != nil {
 			return nil, err
 		}
 
@@ -69,18 +328,31 @@ func (s *AppointmentService) ConfirmAppointment(ctx context.Context, appointment
 	return result.(*models.Appointment), nil
 }
 
-// RejectAppointment rejects an appointment
-func (s *AppointmentService) RejectAppointment(ctx context.Context, appointmentID uint) (*models.Appointment, error) {
+// RejectAppointment rejects a pending appointment and releases its time
+// slot. Both the master and the admin reject routes go through here.
+func (s *AppointmentService) RejectAppointment(ctx context.Context, actorID, appointmentID uint, reason string) (*models.Appointment, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxCancellationReasonLength {
+		return nil, ErrRejectionReason
+	}
+
 	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
 		// Get the appointment
-		appointment, err := s.appointmentRepo.GetByID(ctx, tx, appointmentID)
+		appointment, err := s.appointmentRepo.GetByIDForUpdate(ctx, tx, appointmentID)
 		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
 			return nil, err
 		}
 
 		// Update status to rejected
-		appointment.Status = models.StatusRejected
-		if err := s.appointmentRepo.Update(ctx, tx, appointment); err != nil {
+		if err := transitionAppointment(ctx, tx, s.appointmentRepo, s.transitionRepo, appointment, models.StatusRejected, &actorID, reason); err != nil {
+			return nil, err
+		}
+
+		// Free the service slot the request held so the time can be booked again
+		if err := s.timeslotRepo.ReleaseAllSlotsAtTime(ctx, tx, appointment.MasterID, appointment.StartTime, appointment.EndTime); err != nil {
 			return nil, err
 		}
 
@@ -98,3 +370,296 @@ func (s *AppointmentService) RejectAppointment(ctx context.Context, appointmentI
 	}
 	return result.(*models.Appointment), nil
 }
+
+// CancelAppointment cancels one of the user's own appointments, as long as
+// the master's cancellation notice period has not started yet
+func (s *AppointmentService) CancelAppointment(ctx context.Context, userID, appointmentID uint, reason string) (*models.Appointment, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxCancellationReasonLength {
+		return nil, ErrCancellationReason
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		appointment, err := s.appointmentRepo.GetByIDForUpdate(ctx, tx, appointmentID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if appointment.UserID == nil || *appointment.UserID != userID {
+			return nil, apperrors.ErrNotFound
+		}
+		if err := checkCancellable(appointment, time.Now()); err != nil {
+			return nil, err
+		}
+
+		if err := cancelAppointment(ctx, tx, s.appointmentRepo, s.timeslotRepo, s.transitionRepo, appointment, &userID, reason); err != nil {
+			return nil, err
+		}
+		return s.appointmentRepo.GetByID(ctx, tx, appointment.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Appointment), nil
+}
+
+// RescheduleByClient moves one of the user's own appointments to a new start
+// time, subject to the master's cancellation policy. A confirmed booking goes
+// back to pending so the master can approve the new time.
+func (s *AppointmentService) RescheduleByClient(ctx context.Context, userID, appointmentID uint, startTime time.Time) (*models.Appointment, error) {
+	return s.reschedule(ctx, &userID, appointmentID, startTime, func(appointment *models.Appointment, now time.Time) (models.AppointmentStatus, error) {
+		if appointment.UserID == nil || *appointment.UserID != userID {
+			return "", apperrors.ErrNotFound
+		}
+		if err := checkCancellable(appointment, now); err != nil {
+			if errors.Is(err, ErrAppointmentNotCancellable) {
+				return "", ErrAppointmentNotReschedulable
+			}
+			return "", err
+		}
+		return models.StatusPending, nil
+	})
+}
+
+// RescheduleByMaster moves an appointment booked with the master to a new
+// start time. The appointment keeps its status.
+func (s *AppointmentService) RescheduleByMaster(ctx context.Context, masterUserID, appointmentID uint, startTime time.Time) (*models.Appointment, error) {
+	profile, err := s.masterRepo.GetByUserID(ctx, nil, masterUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return s.reschedule(ctx, &masterUserID, appointmentID, startTime, func(appointment *models.Appointment, now time.Time) (models.AppointmentStatus, error) {
+		if appointment.MasterID != profile.ID {
+			return "", apperrors.ErrNotFound
+		}
+		if appointment.Status != models.StatusPending && appointment.Status != models.StatusConfirmed {
+			return "", ErrAppointmentNotReschedulable
+		}
+		if !appointment.StartTime.After(now) {
+			return "", ErrAppointmentNotReschedulable
+		}
+		return appointment.Status, nil
+	})
+}
+
+// reschedule moves the appointment and its time slot bookings to the new
+// start time in one transaction, keeping its length. authorize checks the
+// caller may move the appointment and returns the status it should have
+// afterwards. The new time must be free according to the availability
+// service, like a new booking.
+func (s *AppointmentService) reschedule(
+	ctx context.Context,
+	actorID *uint,
+	appointmentID uint,
+	startTime time.Time,
+	authorize func(appointment *models.Appointment, now time.Time) (models.AppointmentStatus, error),
+) (*models.Appointment, error) {
+	now := time.Now()
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		appointment, err := s.appointmentRepo.GetByIDForUpdate(ctx, tx, appointmentID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		// Lock the master so concurrent bookings cannot take the new time
+		master, err := s.masterRepo.GetByIDForUpdate(ctx, tx, appointment.MasterID)
+		if err != nil {
+			return nil, err
+		}
+		status, err := authorize(appointment, now)
+		if err != nil {
+			return nil, err
+		}
+
+		// Free the old time first so its slots do not count as a conflict
+		if err := s.timeslotRepo.ReleaseAllSlotsAtTime(ctx, tx, appointment.MasterID, appointment.StartTime, appointment.EndTime); err != nil {
+			return nil, err
+		}
+		endTime := startTime.Add(appointment.EndTime.Sub(appointment.StartTime))
+		if err := s.availability.CheckFree(ctx, tx, master, startTime, endTime, Ignore{AppointmentID: appointment.ID}); err != nil {
+			return nil, err
+		}
+
+		appointment.StartTime = startTime
+		appointment.EndTime = endTime
+		if status != appointment.Status {
+			err = transitionAppointment(ctx, tx, s.appointmentRepo, s.transitionRepo, appointment, status, actorID, clientRescheduledReason)
+		} else {
+			err = s.appointmentRepo.Update(ctx, tx, appointment)
+		}
+		if err != nil {
+			if errors.Is(err, repositories.ErrAppointmentOverlap) {
+				return nil, ErrTimeSlotConflict
+			}
+			return nil, err
+		}
+
+		if appointment.Status == models.StatusConfirmed {
+			// Mark ALL time slots at the new time as booked (master has unified calendar)
+			if err := s.timeslotRepo.BookAllSlotsAtTime(ctx, tx, appointment.MasterID, startTime, endTime); err != nil {
+				return nil, err
+			}
+			if err := s.timeslotRepo.EnsureSlotExists(ctx, tx, appointment); err != nil {
+				return nil, err
+			}
+		} else if err := s.timeslotRepo.BookServiceSlot(ctx, tx, appointment.MasterID, appointment.ServiceID, startTime, endTime); err != nil {
+			return nil, err
+		}
+
+		return s.appointmentRepo.GetByID(ctx, tx, appointment.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Appointment), nil
+}
+
+// MarkAttendance records whether the client of one of the master's
+// appointments turned up, setting it completed or no-show. It can be changed
+// again later to correct a mistake.
+func (s *AppointmentService) MarkAttendance(ctx context.Context, masterUserID, appointmentID uint, status models.AppointmentStatus, reason string) (*models.Appointment, error) {
+	if status != models.StatusCompleted && status != models.StatusNoShow {
+		return nil, ErrAttendanceStatus
+	}
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxCancellationReasonLength {
+		return nil, ErrAttendanceReason
+	}
+
+	profile, err := s.masterRepo.GetByUserID(ctx, nil, masterUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		appointment, err := s.appointmentRepo.GetByIDForUpdate(ctx, tx, appointmentID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if appointment.MasterID != profile.ID {
+			return nil, apperrors.ErrNotFound
+		}
+		if appointment.StartTime.After(time.Now()) {
+			return nil, ErrAttendanceTooEarly
+		}
+
+		if err := transitionAppointment(ctx, tx, s.appointmentRepo, s.transitionRepo, appointment, status, &masterUserID, reason); err != nil {
+			return nil, err
+		}
+		return s.appointmentRepo.GetByID(ctx, tx, appointment.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Appointment), nil
+}
+
+// CompleteFinished marks confirmed appointments that have ended as
+// completed. It is run periodically by a background job.
+func (s *AppointmentService) CompleteFinished(ctx context.Context) error {
+	appointments, err := s.appointmentRepo.ListConfirmedEndedBefore(ctx, nil, time.Now(), autoCompleteBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, ended := range appointments {
+		_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+			appointment, err := s.appointmentRepo.GetByIDForUpdate(ctx, tx, ended.ID)
+			if err != nil {
+				return nil, err
+			}
+			// The master may have recorded attendance in the meantime
+			if appointment.Status != models.StatusConfirmed {
+				return nil, nil
+			}
+			return nil, transitionAppointment(ctx, tx, s.appointmentRepo, s.transitionRepo, appointment, models.StatusCompleted, nil, autoCompletedReason)
+		})
+		if err != nil {
+			return fmt.Errorf("completing appointment %d: %w", ended.ID, err)
+		}
+	}
+	return nil
+}
+
+// ClientNoShows counts the no-shows of each of the master's clients
+func (s *AppointmentService) ClientNoShows(ctx context.Context, masterID uint) (*repositories.NoShowCounts, error) {
+	return s.appointmentRepo.CountNoShowsForMaster(ctx, nil, masterID)
+}
+
+// checkEmailVerified returns ErrEmailNotVerified when bookings require a
+// verified email and the user has not confirmed theirs
+func checkEmailVerified(ctx context.Context, tx *gorm.DB, userRepo repositories.UserRepository, cfg *config.Config, userID uint) error {
+	if !cfg.RequireVerifiedEmail {
+		return nil
+	}
+	user, err := userRepo.GetByID(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound
+		}
+		return err
+	}
+	if user.VerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// checkCancellable returns an error unless the client may still cancel the
+// appointment under its master's cancellation policy. Master must be loaded.
+func checkCancellable(appointment *models.Appointment, now time.Time) error {
+	if !canTransition(appointment.Status, models.StatusCancelled) {
+		return ErrAppointmentNotCancellable
+	}
+	if !appointment.StartTime.After(now) {
+		return ErrAppointmentNotCancellable
+	}
+
+	hours := appointment.Master.CancellationNoticeHours
+	if hours > 0 && appointment.StartTime.Sub(now) < time.Duration(hours)*time.Hour {
+		return apperrors.New("CANCELLATION_TOO_LATE",
+			fmt.Sprintf("Appointments with this master cannot be cancelled or moved less than %d hours before they start", hours),
+			http.StatusConflict)
+	}
+	return nil
+}
+
+// cancelAppointment marks the appointment cancelled and frees the time slots
+// booked for it, if it held any. cancelledBy is nil when a guest cancels.
+func cancelAppointment(
+	ctx context.Context,
+	tx *gorm.DB,
+	appointmentRepo repositories.AppointmentRepository,
+	timeslotRepo repositories.TimeslotRepository,
+	transitionRepo repositories.AppointmentTransitionRepository,
+	appointment *models.Appointment,
+	cancelledBy *uint,
+	reason string,
+) error {
+	blocking := appointment.Status != models.StatusUnconfirmed
+	now := time.Now()
+	appointment.CancelledAt = &now
+	appointment.CancelledByID = cancelledBy
+	appointment.CancellationReason = reason
+	if err := transitionAppointment(ctx, tx, appointmentRepo, transitionRepo, appointment, models.StatusCancelled, cancelledBy, reason); err != nil {
+		return err
+	}
+	if !blocking {
+		return nil
+	}
+	return timeslotRepo.ReleaseAllSlotsAtTime(ctx, tx, appointment.MasterID, appointment.StartTime, appointment.EndTime)
+}
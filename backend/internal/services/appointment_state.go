@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// appointmentTransitions lists the statuses an appointment may move to from
+// each status. Rejected and cancelled appointments are final; a master may
+// correct attendance by switching between completed and no-show.
+var appointmentTransitions = map[models.AppointmentStatus][]models.AppointmentStatus{
+	models.StatusUnconfirmed: {models.StatusPending, models.StatusCancelled},
+	models.StatusPending:     {models.StatusConfirmed, models.StatusRejected, models.StatusCancelled},
+	models.StatusConfirmed:   {models.StatusPending, models.StatusCancelled, models.StatusCompleted, models.StatusNoShow},
+	models.StatusCompleted:   {models.StatusNoShow},
+	models.StatusNoShow:      {models.StatusCompleted},
+}
+
+// canTransition reports whether an appointment may move between the statuses
+func canTransition(from, to models.AppointmentStatus) bool {
+	for _, status := range appointmentTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// errInvalidTransition is returned for a status change the state machine does not allow
+func errInvalidTransition(from, to models.AppointmentStatus) error {
+	return apperrors.New("INVALID_STATUS_TRANSITION",
+		fmt.Sprintf("A %s appointment cannot be changed to %s", from, to),
+		http.StatusConflict)
+}
+
+// transitionAppointment moves the appointment to a new status, saves it with
+// any other changes the caller made and records who changed the status and
+// why. actorID is nil when a guest or the system makes the change.
+func transitionAppointment(
+	ctx context.Context,
+	tx *gorm.DB,
+	appointmentRepo repositories.AppointmentRepository,
+	transitionRepo repositories.AppointmentTransitionRepository,
+	appointment *models.Appointment,
+	to models.AppointmentStatus,
+	actorID *uint,
+	reason string,
+) error {
+	from := appointment.Status
+	if !canTransition(from, to) {
+		return errInvalidTransition(from, to)
+	}
+
+	appointment.Status = to
+	if err := appointmentRepo.Update(ctx, tx, appointment); err != nil {
+		return err
+	}
+	return transitionRepo.Create(ctx, tx, &models.AppointmentTransition{
+		AppointmentID: appointment.ID,
+		FromStatus:    from,
+		ToStatus:      to,
+		ActorID:       actorID,
+		Reason:        reason,
+	})
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+)
+
+var allStatuses = []models.AppointmentStatus{
+	models.StatusUnconfirmed,
+	models.StatusPending,
+	models.StatusConfirmed,
+	models.StatusRejected,
+	models.StatusCancelled,
+	models.StatusCompleted,
+	models.StatusNoShow,
+}
+
+func TestCanTransition(t *testing.T) {
+	allowed := map[models.AppointmentStatus][]models.AppointmentStatus{
+		models.StatusUnconfirmed: {models.StatusPending, models.StatusCancelled},
+		models.StatusPending:     {models.StatusConfirmed, models.StatusRejected, models.StatusCancelled},
+		models.StatusConfirmed:   {models.StatusPending, models.StatusCancelled, models.StatusCompleted, models.StatusNoShow},
+		models.StatusCompleted:   {models.StatusNoShow},
+		models.StatusNoShow:      {models.StatusCompleted},
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			want := false
+			for _, status := range allowed[from] {
+				if status == to {
+					want = true
+				}
+			}
+			if got := canTransition(from, to); got != want {
+				t.Errorf("canTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestCanTransitionFinalStatuses(t *testing.T) {
+	for _, from := range []models.AppointmentStatus{models.StatusRejected, models.StatusCancelled} {
+		for _, to := range allStatuses {
+			if canTransition(from, to) {
+				t.Errorf("%s appointments are final, but may move to %s", from, to)
+			}
+		}
+	}
+}
+
+func TestCanTransitionUnknownStatus(t *testing.T) {
+	tests := []struct {
+		from, to models.AppointmentStatus
+	}{
+		{"", models.StatusPending},
+		{"archived", models.StatusCancelled},
+		{models.StatusPending, "archived"},
+		{models.StatusPending, ""},
+	}
+	for _, tt := range tests {
+		if canTransition(tt.from, tt.to) {
+			t.Errorf("canTransition(%q, %q) = true, want false", tt.from, tt.to)
+		}
+	}
+}
+
+func TestErrInvalidTransition(t *testing.T) {
+	err := errInvalidTransition(models.StatusCancelled, models.StatusConfirmed)
+
+	var appErr *apperrors.AppError
+	if !errors.As(err, &appErr) {
+		t.Fatalf("errInvalidTransition returned %T, want *AppError", err)
+	}
+	if appErr.Status != http.StatusConflict {
+		t.Errorf("status = %d, want %d", appErr.Status, http.StatusConflict)
+	}
+	if appErr.Code != "INVALID_STATUS_TRANSITION" {
+		t.Errorf("code = %q, want INVALID_STATUS_TRANSITION", appErr.Code)
+	}
+	if want := "A cancelled appointment cannot be changed to confirmed"; appErr.Message != want {
+		t.Errorf("message = %q, want %q", appErr.Message, want)
+	}
+}
@@ -0,0 +1,490 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/jwtkeys"
+	"github.com/timebook/backend/internal/middleware"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// Authentication errors
+var (
+	ErrInvalidRefreshToken = apperrors.New("INVALID_REFRESH_TOKEN", "Invalid or expired refresh token", http.StatusUnauthorized)
+	ErrTokenRevoked        = apperrors.New("TOKEN_REVOKED", "Token has been revoked", http.StatusUnauthorized)
+	ErrAccountSuspended    = apperrors.New("ACCOUNT_SUSPENDED", "This account has been suspended", http.StatusForbidden)
+)
+
+// lastSeenResolution limits how often a session's last-seen timestamp is written
+const lastSeenResolution = time.Minute
+
+// maxUserAgentLength matches the sessions.user_agent column
+const maxUserAgentLength = 255
+
+// ClientInfo describes the device a session is started or refreshed from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// TokenPair is the result of a successful login or refresh
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// AuthService handles issuing, rotating and revoking authentication tokens
+type AuthService struct {
+	userRepo          repositories.UserRepository
+	refreshTokenRepo  repositories.RefreshTokenRepository
+	sessionRepo       repositories.SessionRepository
+	roleGrantRepo     repositories.RoleGrantRepository
+	impersonationRepo repositories.ImpersonationRepository
+	txManager         *transaction.Manager
+	keys              *jwtkeys.KeySet
+	config            *config.Config
+}
+
+// NewAuthService creates a new auth service
+func NewAuthService(
+	userRepo repositories.UserRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	sessionRepo repositories.SessionRepository,
+	roleGrantRepo repositories.RoleGrantRepository,
+	impersonationRepo repositories.ImpersonationRepository,
+	txManager *transaction.Manager,
+	keys *jwtkeys.KeySet,
+	cfg *config.Config,
+) *AuthService {
+	return &AuthService{
+		userRepo:          userRepo,
+		refreshTokenRepo:  refreshTokenRepo,
+		sessionRepo:       sessionRepo,
+		roleGrantRepo:     roleGrantRepo,
+		impersonationRepo: impersonationRepo,
+		txManager:         txManager,
+		keys:              keys,
+		config:            cfg,
+	}
+}
+
+// IssueTokens starts a new session for the user and returns its first
+// access/refresh token pair. The user's role grants are (re)loaded so the
+// token carries every role they hold.
+func (s *AuthService) IssueTokens(ctx context.Context, user *models.User, client ClientInfo) (*TokenPair, error) {
+	if user.Suspended() {
+		return nil, ErrAccountSuspended
+	}
+
+	grants, err := s.roleGrantRepo.ListForUser(ctx, nil, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	user.RoleGrants = make([]models.RoleGrant, 0, len(grants))
+	for _, grant := range grants {
+		user.RoleGrants = append(user.RoleGrants, *grant)
+	}
+
+	sessionID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		now := time.Now()
+		session := &models.Session{
+			SessionID:  sessionID,
+			UserID:     user.ID,
+			UserAgent:  truncate(client.UserAgent, maxUserAgentLength),
+			IPAddress:  client.IPAddress,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(s.config.RefreshTokenTTL),
+		}
+		if err := s.sessionRepo.Create(ctx, tx, session); err != nil {
+			return nil, err
+		}
+		return s.createRefreshToken(ctx, tx, user.ID, sessionID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshToken := result.(string)
+
+	accessToken, expiresAt, err := s.newAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token is consumed; presenting it again is treated as theft and revokes
+// every token in its session.
+func (s *AuthService) Refresh(ctx context.Context, rawToken string, client ClientInfo) (*TokenPair, *models.User, error) {
+	type refreshResult struct {
+		pair *TokenPair
+		user *models.User
+	}
+
+	var reused *models.RefreshToken
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		stored, err := s.refreshTokenRepo.GetByHashForUpdate(ctx, tx, hashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidRefreshToken
+			}
+			return nil, err
+		}
+
+		if stored.UsedAt != nil || stored.RevokedAt != nil {
+			reused = stored
+			return nil, ErrInvalidRefreshToken
+		}
+		if time.Now().After(stored.ExpiresAt) {
+			return nil, ErrInvalidRefreshToken
+		}
+
+		session, err := s.sessionRepo.GetBySessionID(ctx, tx, stored.SessionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidRefreshToken
+			}
+			return nil, err
+		}
+		if session.RevokedAt != nil {
+			return nil, ErrInvalidRefreshToken
+		}
+
+		user, err := s.userRepo.GetByID(ctx, tx, stored.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidRefreshToken
+			}
+			return nil, err
+		}
+		if user.Suspended() {
+			return nil, ErrAccountSuspended
+		}
+
+		now := time.Now()
+		stored.UsedAt = &now
+		if err := s.refreshTokenRepo.Update(ctx, tx, stored); err != nil {
+			return nil, err
+		}
+
+		session.LastSeenAt = now
+		session.ExpiresAt = now.Add(s.config.RefreshTokenTTL)
+		session.IPAddress = client.IPAddress
+		if client.UserAgent != "" {
+			session.UserAgent = truncate(client.UserAgent, maxUserAgentLength)
+		}
+		if err := s.sessionRepo.Update(ctx, tx, session); err != nil {
+			return nil, err
+		}
+
+		refreshToken, err := s.createRefreshToken(ctx, tx, user.ID, stored.SessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		accessToken, expiresAt, err := s.newAccessToken(user, stored.SessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &refreshResult{
+			pair: &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresAt: expiresAt},
+			user: user,
+		}, nil
+	})
+
+	// Reuse detection: revoke outside the rolled-back transaction so the
+	// revocation sticks
+	if reused != nil {
+		if revokeErr := s.revokeSession(ctx, nil, reused.SessionID); revokeErr != nil {
+			return nil, nil, revokeErr
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := result.(*refreshResult)
+	return res.pair, res.user, nil
+}
+
+// Logout revokes the session the refresh token belongs to. Unknown tokens
+// are ignored so that logging out is idempotent.
+func (s *AuthService) Logout(ctx context.Context, rawToken string) error {
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		stored, err := s.refreshTokenRepo.GetByHashForUpdate(ctx, tx, hashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return nil, s.revokeSession(ctx, tx, stored.SessionID)
+	})
+	return err
+}
+
+// ValidateToken implements middleware.TokenValidator. It rejects access
+// tokens whose session has been revoked or has expired and tokens whose
+// roles no longer match the roles the user currently holds.
+func (s *AuthService) ValidateToken(ctx context.Context, claims *middleware.Claims) error {
+	if claims.ImpersonationID != 0 {
+		return s.validateImpersonation(ctx, claims)
+	}
+	if claims.SessionID == "" {
+		return ErrTokenRevoked
+	}
+
+	if err := s.checkSession(ctx, claims.SessionID, claims.UserID); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, nil, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	if user.Suspended() {
+		return ErrAccountSuspended
+	}
+	if string(user.Role) != claims.Role || !sameRoles(roleNames(user), claims.Roles) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// validateImpersonation checks an impersonation token: the impersonation
+// must still be open, the admin's own session active and the admin still an
+// admin, and the effective user must not have gained the admin role
+func (s *AuthService) validateImpersonation(ctx context.Context, claims *middleware.Claims) error {
+	impersonation, err := s.impersonationRepo.GetByID(ctx, nil, claims.ImpersonationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	if impersonation.EndedAt != nil ||
+		time.Now().After(impersonation.ExpiresAt) ||
+		impersonation.AdminID != claims.ImpersonatorID ||
+		impersonation.UserID != claims.UserID {
+		return ErrTokenRevoked
+	}
+
+	if err := s.checkSession(ctx, impersonation.AdminSessionID, impersonation.AdminID); err != nil {
+		return err
+	}
+
+	admin, err := s.userRepo.GetByID(ctx, nil, impersonation.AdminID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	user, err := s.userRepo.GetByID(ctx, nil, impersonation.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	if admin.Suspended() {
+		return ErrAccountSuspended
+	}
+	if !admin.HasRole(models.RoleAdmin) || user.HasRole(models.RoleAdmin) ||
+		string(user.Role) != claims.Role || !sameRoles(roleNames(user), claims.Roles) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// checkSession returns ErrTokenRevoked unless the session belongs to the user
+// and is still active, and records that it was just used
+func (s *AuthService) checkSession(ctx context.Context, sessionID string, userID uint) error {
+	session, err := s.sessionRepo.GetBySessionID(ctx, nil, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	now := time.Now()
+	if session.UserID != userID || session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return ErrTokenRevoked
+	}
+
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		if err := s.sessionRepo.TouchLastSeen(ctx, nil, session.SessionID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func (s *AuthService) ListSessions(ctx context.Context, userID uint) ([]*models.Session, error) {
+	return s.sessionRepo.ListActiveForUser(ctx, nil, userID)
+}
+
+// RevokeSession signs one of the user's sessions out. Revoking an already
+// revoked session is a no-op.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, id uint) error {
+	session, err := s.sessionRepo.GetByIDForUser(ctx, nil, id, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound
+		}
+		return err
+	}
+
+	_, err = s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		return nil, s.revokeSession(ctx, tx, session.SessionID)
+	})
+	return err
+}
+
+// RevokeOtherSessions signs the user out of every session except the
+// current one and returns how many sessions were revoked
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID uint, currentSessionID string) (int, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		return revokeUserSessions(ctx, tx, s.sessionRepo, s.refreshTokenRepo, userID, currentSessionID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
+// revokeSession revokes a session and every refresh token issued for it
+func (s *AuthService) revokeSession(ctx context.Context, tx *gorm.DB, sessionID string) error {
+	if err := s.sessionRepo.Revoke(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	return s.refreshTokenRepo.RevokeSession(ctx, tx, sessionID)
+}
+
+// revokeUserSessions revokes all of a user's sessions except
+// exceptSessionID, along with their refresh tokens
+func revokeUserSessions(
+	ctx context.Context,
+	tx *gorm.DB,
+	sessionRepo repositories.SessionRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	userID uint,
+	exceptSessionID string,
+) (int, error) {
+	sessionIDs, err := sessionRepo.RevokeAllForUser(ctx, tx, userID, exceptSessionID)
+	if err != nil {
+		return 0, err
+	}
+	if err := refreshTokenRepo.RevokeSessions(ctx, tx, sessionIDs); err != nil {
+		return 0, err
+	}
+	return len(sessionIDs), nil
+}
+
+// createRefreshToken stores a new refresh token for the session and returns
+// its raw value
+func (s *AuthService) createRefreshToken(ctx context.Context, tx *gorm.DB, userID uint, sessionID string) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := &models.RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(s.config.RefreshTokenTTL),
+	}
+	if err := s.refreshTokenRepo.Create(ctx, tx, token); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// newAccessToken signs a short-lived access token bound to a session
+func (s *AuthService) newAccessToken(user *models.User, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.config.AccessTokenTTL)
+
+	claims := &middleware.Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      string(user.Role),
+		Roles:     roleNames(user),
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	signed, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+// roleNames returns the user's roles as claim values
+func roleNames(user *models.User) []string {
+	roles := user.Roles()
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return names
+}
+
+// sameRoles reports whether two role lists hold the same roles in any order
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	held := map[string]bool{}
+	for _, role := range a {
+		held[role] = true
+	}
+	for _, role := range b {
+		if !held[role] {
+			return false
+		}
+	}
+	return true
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
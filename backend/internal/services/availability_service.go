@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// maxSlotDays limits how many days of slots one request can list
+const maxSlotDays = 31
+
+// Availability errors
+var (
+	ErrSlotInPast          = apperrors.New("VALIDATION_ERROR", "Appointments must start in the future", http.StatusBadRequest)
+	ErrOutsideWorkingHours = apperrors.New("OUTSIDE_WORKING_HOURS", "The master does not work at this time", http.StatusConflict)
+	ErrTimeSlotConflict    = apperrors.New("TIME_SLOT_CONFLICT", "Time slot conflicts with existing appointment", http.StatusConflict)
+)
+
+// AvailableSlot is one bookable start time in a master's calendar
+type AvailableSlot struct {
+	StartTime time.Time
+	EndTime   time.Time
+	IsBooked  bool
+	IsPast    bool
+}
+
+// Available reports whether the slot can be booked
+func (s AvailableSlot) Available() bool {
+	return !s.IsBooked && !s.IsPast
+}
+
+// Ignore names calendar entries that should not count as busy: the
+// appointment being moved or the hold being booked
+type Ignore struct {
+	AppointmentID uint
+	HoldID        uint
+}
+
+// AvailabilityService decides when a master can be booked. Every booking
+// path and the slot listing shown to clients use the same rules: the time
+// must be in the future, inside the master's working hours and clear of
+// their pending and confirmed appointments, booked time slots and other
+// clients' unexpired holds, whatever service those are for. Working hours
+// are read in the master's time zone, or in the configured default zone
+// for masters who have not set one.
+type AvailabilityService struct {
+	appointmentRepo repositories.AppointmentRepository
+	timeslotRepo    repositories.TimeslotRepository
+	masterRepo      repositories.MasterRepository
+	holdRepo        repositories.SlotHoldRepository
+	defaultLocation *time.Location
+}
+
+// NewAvailabilityService creates a new availability service
+func NewAvailabilityService(
+	appointmentRepo repositories.AppointmentRepository,
+	timeslotRepo repositories.TimeslotRepository,
+	masterRepo repositories.MasterRepository,
+	holdRepo repositories.SlotHoldRepository,
+	cfg *config.Config,
+) *AvailabilityService {
+	// The zone name is checked when the configuration is loaded
+	defaultLocation, err := time.LoadLocation(cfg.DefaultTimeZone)
+	if err != nil {
+		defaultLocation = time.UTC
+	}
+	return &AvailabilityService{
+		appointmentRepo: appointmentRepo,
+		timeslotRepo:    timeslotRepo,
+		masterRepo:      masterRepo,
+		holdRepo:        holdRepo,
+		defaultLocation: defaultLocation,
+	}
+}
+
+// CheckFree returns nil when the master can be booked from start to end,
+// apart from the entries in ignore. Callers that go on to book or hold the
+// time must hold the master's row lock so the answer stays true until they
+// commit.
+func (s *AvailabilityService) CheckFree(ctx context.Context, tx *gorm.DB, master *models.MasterProfile, start, end time.Time, ignore Ignore) error {
+	if !start.After(time.Now()) {
+		return ErrSlotInPast
+	}
+	if !withinWorkingHours(master, masterLocation(master, s.defaultLocation), start, end) {
+		return ErrOutsideWorkingHours
+	}
+
+	calendar, err := s.loadCalendar(ctx, tx, master.ID, start, end)
+	if err != nil {
+		return err
+	}
+	if calendar.busy(start, end, ignore) {
+		return ErrTimeSlotConflict
+	}
+	return nil
+}
+
+// Slots lists the master's hourly start times between from and to, each
+// lasting the given duration, marking those that cannot be booked
+func (s *AvailabilityService) Slots(ctx context.Context, masterID uint, duration time.Duration, from, to time.Time) ([]AvailableSlot, error) {
+	master, err := s.masterRepo.GetByID(ctx, nil, masterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+	if limit := from.AddDate(0, 0, maxSlotDays); to.After(limit) {
+		to = limit
+	}
+
+	calendar, err := s.loadCalendar(ctx, nil, master.ID, from, to.Add(duration))
+	if err != nil {
+		return nil, err
+	}
+
+	return slotGrid(master, masterLocation(master, s.defaultLocation), calendar, duration, from, to, time.Now()), nil
+}
+
+// slotGrid lays out the hourly start times inside the master's working
+// hours in loc between from and to, marking those that cannot be booked at
+// now
+func slotGrid(master *models.MasterProfile, loc *time.Location, calendar *calendar, duration time.Duration, from, to, now time.Time) []AvailableSlot {
+	local := from.In(loc)
+	first := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	slots := []AvailableSlot{}
+	for day := first; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for hour := master.WorkdayStartHour; hour < master.WorkdayEndHour; hour++ {
+			start := time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, loc)
+			end := start.Add(duration)
+			if start.Before(from) || !start.Before(to) || !withinWorkingHours(master, loc, start, end) {
+				continue
+			}
+			slots = append(slots, AvailableSlot{
+				StartTime: start,
+				EndTime:   end,
+				IsBooked:  calendar.busy(start, end, Ignore{}),
+				IsPast:    !start.After(now),
+			})
+		}
+	}
+	return slots
+}
+
+// loadCalendar fetches what occupies the master's time between from and to
+func (s *AvailabilityService) loadCalendar(ctx context.Context, tx *gorm.DB, masterID uint, from, to time.Time) (*calendar, error) {
+	appointments, err := s.appointmentRepo.ListActiveForMasterBetween(ctx, tx, masterID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := s.timeslotRepo.ListBookedForMasterBetween(ctx, tx, masterID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := s.holdRepo.ListActiveForMasterBetween(ctx, tx, masterID, from, to, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &calendar{appointments: appointments, blocked: blocked, holds: holds}, nil
+}
+
+// calendar is what occupies a master's time: pending and confirmed
+// appointments, booked time slots, which include those the master blocked
+// by hand, and unexpired holds
+type calendar struct {
+	appointments []*models.Appointment
+	blocked      []*models.TimeSlot
+	holds        []*models.SlotHold
+}
+
+// busy reports whether anything in the calendar overlaps start to end
+func (c *calendar) busy(start, end time.Time, ignore Ignore) bool {
+	for _, appointment := range c.appointments {
+		if appointment.ID != ignore.AppointmentID && appointment.StartTime.Before(end) && appointment.EndTime.After(start) {
+			return true
+		}
+	}
+	for _, hold := range c.holds {
+		if hold.ID != ignore.HoldID && hold.StartTime.Before(end) && hold.EndTime.After(start) {
+			return true
+		}
+	}
+	for _, slot := range c.blocked {
+		if slot.StartTime.Before(end) && slot.EndTime.After(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinWorkingHours reports whether start to end falls inside the master's
+// working hours on the day it starts in loc
+func withinWorkingHours(master *models.MasterProfile, loc *time.Location, start, end time.Time) bool {
+	local := start.In(loc)
+	open := time.Date(local.Year(), local.Month(), local.Day(), master.WorkdayStartHour, 0, 0, 0, loc)
+	closing := time.Date(local.Year(), local.Month(), local.Day(), master.WorkdayEndHour, 0, 0, 0, loc)
+	return !start.Before(open) && !end.After(closing)
+}
+
+// masterLocation returns the master's time zone, or fallback when they have
+// not set one
+func masterLocation(master *models.MasterProfile, fallback *time.Location) *time.Location {
+	if master.TimeZone == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(master.TimeZone)
+	if err != nil {
+		return fallback
+	}
+	return loc
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timebook/backend/internal/models"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestMasterLocation(t *testing.T) {
+	fallback := mustLoadLocation(t, "Europe/Berlin")
+
+	tests := []struct {
+		name     string
+		timeZone string
+		want     string
+	}{
+		{"set", "America/New_York", "America/New_York"},
+		{"empty uses fallback", "", "Europe/Berlin"},
+		{"unknown uses fallback", "Mars/Olympus_Mons", "Europe/Berlin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := masterLocation(&models.MasterProfile{TimeZone: tt.timeZone}, fallback)
+			if got.String() != tt.want {
+				t.Errorf("masterLocation = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithinWorkingHours(t *testing.T) {
+	master := &models.MasterProfile{WorkdayStartHour: 9, WorkdayEndHour: 17}
+	newYork := mustLoadLocation(t, "America/New_York")
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 12, hour, minute, 0, 0, time.UTC)
+	}
+
+	// New York is five hours behind UTC in January
+	tests := []struct {
+		name       string
+		loc        *time.Location
+		start, end time.Time
+		want       bool
+	}{
+		{"opening hour", time.UTC, at(9, 0), at(10, 0), true},
+		{"before opening", time.UTC, at(8, 59), at(9, 59), false},
+		{"ends at closing", time.UTC, at(16, 0), at(17, 0), true},
+		{"runs past closing", time.UTC, at(16, 30), at(17, 30), false},
+		{"starts at closing", time.UTC, at(17, 0), at(18, 0), false},
+		{"night", time.UTC, at(3, 0), at(4, 0), false},
+		{"inside hours in UTC, before opening in New York", newYork, at(9, 0), at(10, 0), false},
+		{"after closing in UTC, inside hours in New York", newYork, at(20, 0), at(21, 0), true},
+		{"opening in New York", newYork, at(14, 0), at(15, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinWorkingHours(master, tt.loc, tt.start, tt.end); got != tt.want {
+				t.Errorf("withinWorkingHours(%s, %s) in %s = %v, want %v", tt.start, tt.end, tt.loc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarBusy(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2026, 1, 12, hour, 0, 0, 0, time.UTC)
+	}
+	c := &calendar{
+		appointments: []*models.Appointment{{ID: 1, StartTime: at(10), EndTime: at(11)}},
+		holds:        []*models.SlotHold{{ID: 7, StartTime: at(12), EndTime: at(13)}},
+		blocked:      []*models.TimeSlot{{ID: 3, StartTime: at(14), EndTime: at(15)}},
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		ignore     Ignore
+		want       bool
+	}{
+		{"free", at(8), at(9), Ignore{}, false},
+		{"overlaps appointment", at(10), at(11), Ignore{}, true},
+		{"partly overlaps appointment", at(9), at(11), Ignore{}, true},
+		{"ends when appointment starts", at(9), at(10), Ignore{}, false},
+		{"starts when appointment ends", at(11), at(12), Ignore{}, false},
+		{"appointment being moved", at(10), at(11), Ignore{AppointmentID: 1}, false},
+		{"another appointment ignored", at(10), at(11), Ignore{AppointmentID: 2}, true},
+		{"overlaps hold", at(12), at(13), Ignore{}, true},
+		{"hold being booked", at(12), at(13), Ignore{HoldID: 7}, false},
+		{"hold ID does not ignore appointment", at(10), at(11), Ignore{HoldID: 1}, true},
+		{"overlaps blocked slot", at(14), at(15), Ignore{AppointmentID: 3, HoldID: 3}, true},
+		{"spans everything", at(9), at(16), Ignore{AppointmentID: 1, HoldID: 7}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.busy(tt.start, tt.end, tt.ignore); got != tt.want {
+				t.Errorf("busy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlotGrid(t *testing.T) {
+	master := &models.MasterProfile{WorkdayStartHour: 9, WorkdayEndHour: 12}
+	newYork := mustLoadLocation(t, "America/New_York")
+	utc := func(day, hour int) time.Time {
+		return time.Date(2026, 1, day, hour, 0, 0, 0, time.UTC)
+	}
+	empty := &calendar{}
+
+	tests := []struct {
+		name     string
+		loc      *time.Location
+		calendar *calendar
+		duration time.Duration
+		from, to time.Time
+		now      time.Time
+		want     []AvailableSlot
+	}{
+		{
+			name:     "master time zone",
+			loc:      newYork,
+			calendar: empty,
+			duration: time.Hour,
+			from:     time.Date(2026, 1, 12, 0, 0, 0, 0, newYork),
+			to:       time.Date(2026, 1, 13, 0, 0, 0, 0, newYork),
+			now:      utc(1, 0),
+			want: []AvailableSlot{
+				{StartTime: utc(12, 14), EndTime: utc(12, 15)},
+				{StartTime: utc(12, 15), EndTime: utc(12, 16)},
+				{StartTime: utc(12, 16), EndTime: utc(12, 17)},
+			},
+		},
+		{
+			// A client two hours ahead of UTC asks for their day; the
+			// hours are still those of the default zone
+			name:     "default zone",
+			loc:      time.UTC,
+			calendar: empty,
+			duration: time.Hour,
+			from:     utc(11, 22),
+			to:       utc(12, 22),
+			now:      utc(1, 0),
+			want: []AvailableSlot{
+				{StartTime: utc(12, 9), EndTime: utc(12, 10)},
+				{StartTime: utc(12, 10), EndTime: utc(12, 11)},
+				{StartTime: utc(12, 11), EndTime: utc(12, 12)},
+			},
+		},
+		{
+			name:     "longer service must end by closing",
+			loc:      time.UTC,
+			calendar: empty,
+			duration: 2 * time.Hour,
+			from:     utc(12, 0),
+			to:       utc(13, 0),
+			now:      utc(1, 0),
+			want: []AvailableSlot{
+				{StartTime: utc(12, 9), EndTime: utc(12, 11)},
+				{StartTime: utc(12, 10), EndTime: utc(12, 12)},
+			},
+		},
+		{
+			name: "booked, held and past",
+			loc:  time.UTC,
+			calendar: &calendar{
+				appointments: []*models.Appointment{{ID: 1, StartTime: utc(12, 10), EndTime: utc(12, 11)}},
+				holds:        []*models.SlotHold{{ID: 2, StartTime: utc(12, 11), EndTime: utc(12, 12)}},
+			},
+			duration: time.Hour,
+			from:     utc(12, 0),
+			to:       utc(13, 0),
+			now:      utc(12, 9),
+			want: []AvailableSlot{
+				{StartTime: utc(12, 9), EndTime: utc(12, 10), IsPast: true},
+				{StartTime: utc(12, 10), EndTime: utc(12, 11), IsBooked: true},
+				{StartTime: utc(12, 11), EndTime: utc(12, 12), IsBooked: true},
+			},
+		},
+		{
+			name:     "two days",
+			loc:      time.UTC,
+			calendar: empty,
+			duration: 3 * time.Hour,
+			from:     utc(12, 0),
+			to:       utc(14, 0),
+			now:      utc(1, 0),
+			want: []AvailableSlot{
+				{StartTime: utc(12, 9), EndTime: utc(12, 12)},
+				{StartTime: utc(13, 9), EndTime: utc(13, 12)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slotGrid(master, tt.loc, tt.calendar, tt.duration, tt.from, tt.to, tt.now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("slotGrid returned %d slots, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if !g.StartTime.Equal(w.StartTime) || !g.EndTime.Equal(w.EndTime) || g.IsBooked != w.IsBooked || g.IsPast != w.IsPast {
+					t.Errorf("slot %d = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/jwtkeys"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/notify"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// guestBookingAudience keeps manage-booking tokens from being mistaken for
+// access tokens
+const guestBookingAudience = "timebook-guest-booking"
+
+// guestConfirmedReason is recorded when a guest confirms their email
+const guestConfirmedReason = "Guest confirmed their email address"
+
+// Guest booking errors
+var (
+	ErrGuestBookingDisabled = apperrors.New("GUEST_BOOKING_DISABLED", "Booking without an account is not available", http.StatusNotFound)
+	ErrGuestDetails         = apperrors.New("VALIDATION_ERROR", "A name, a valid email address and a phone number are required", http.StatusBadRequest)
+	ErrGuestHasAccount      = apperrors.New("ACCOUNT_EXISTS", "An account with this email already exists. Please log in to book.", http.StatusConflict)
+	ErrInvalidManageToken   = apperrors.New("INVALID_MANAGE_TOKEN", "Invalid or expired booking link", http.StatusBadRequest)
+	ErrGuestNotConfirmable  = apperrors.New("APPOINTMENT_NOT_CONFIRMABLE", "This booking can no longer be confirmed", http.StatusConflict)
+)
+
+// guestBookingClaims are the claims carried by a signed manage-booking token
+type guestBookingClaims struct {
+	AppointmentID uint `json:"appointment_id"`
+	GuestID       uint `json:"guest_id"`
+	jwt.RegisteredClaims
+}
+
+// GuestService handles bookings made without an account
+type GuestService struct {
+	guestRepo       repositories.GuestRepository
+	userRepo        repositories.UserRepository
+	appointmentRepo repositories.AppointmentRepository
+	timeslotRepo    repositories.TimeslotRepository
+	transitionRepo  repositories.AppointmentTransitionRepository
+	masterRepo      repositories.MasterRepository
+	availability    *AvailabilityService
+	notifier        notify.Notifier
+	txManager       *transaction.Manager
+	keys            *jwtkeys.KeySet
+	config          *config.Config
+}
+
+// NewGuestService creates a new guest service
+func NewGuestService(
+	guestRepo repositories.GuestRepository,
+	userRepo repositories.UserRepository,
+	appointmentRepo repositories.AppointmentRepository,
+	timeslotRepo repositories.TimeslotRepository,
+	transitionRepo repositories.AppointmentTransitionRepository,
+	masterRepo repositories.MasterRepository,
+	availability *AvailabilityService,
+	notifier notify.Notifier,
+	txManager *transaction.Manager,
+	keys *jwtkeys.KeySet,
+	cfg *config.Config,
+) *GuestService {
+	return &GuestService{
+		guestRepo:       guestRepo,
+		userRepo:        userRepo,
+		appointmentRepo: appointmentRepo,
+		timeslotRepo:    timeslotRepo,
+		transitionRepo:  transitionRepo,
+		masterRepo:      masterRepo,
+		availability:    availability,
+		notifier:        notifier,
+		txManager:       txManager,
+		keys:            keys,
+		config:          cfg,
+	}
+}
+
+// NewGuest checks the contact details for a guest booking and returns the
+// guest to save along with the appointment. Every booking gets its own guest
+// so that nobody can change the details of another booking by reusing its
+// email address. Emails that belong to an account are refused; their owner
+// has to log in instead. ip is the address the booking came from.
+func (s *GuestService) NewGuest(ctx context.Context, name, email, rawPhone, ip string) (*models.Guest, error) {
+	if !s.config.GuestBookingEnabled {
+		return nil, ErrGuestBookingDisabled
+	}
+
+	name = strings.TrimSpace(name)
+	email = strings.TrimSpace(email)
+	if name == "" || email == "" || strings.TrimSpace(rawPhone) == "" {
+		return nil, ErrGuestDetails
+	}
+	if !validEmail(email) {
+		return nil, ErrGuestDetails
+	}
+	phone, err := normalizePhone(rawPhone, s.config.PhoneDefaultCountryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userRepo.GetByEmail(ctx, nil, email); err == nil {
+		return nil, ErrGuestHasAccount
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return &models.Guest{
+		Name:      name,
+		Email:     email,
+		Phone:     phone,
+		IPAddress: ip,
+	}, nil
+}
+
+// BookingWait applies the per-email and per-address limits on guest
+// bookings. When a limit is hit the returned duration says how long the
+// caller should wait.
+func (s *GuestService) BookingWait(ctx context.Context, guest *models.Guest) (time.Duration, error) {
+	since := time.Now().Add(-s.config.GuestBookingWindow)
+
+	booked, err := s.guestRepo.CountForEmailSince(ctx, nil, guest.Email, since)
+	if err != nil {
+		return 0, err
+	}
+	if s.config.GuestBookingMaxPerEmail > 0 && booked >= int64(s.config.GuestBookingMaxPerEmail) {
+		return s.config.GuestBookingWindow, nil
+	}
+
+	booked, err = s.guestRepo.CountForIPSince(ctx, nil, guest.IPAddress, since)
+	if err != nil {
+		return 0, err
+	}
+	if s.config.GuestBookingMaxPerIP > 0 && booked >= int64(s.config.GuestBookingMaxPerIP) {
+		return s.config.GuestBookingWindow, nil
+	}
+
+	return 0, nil
+}
+
+// IssueManageToken signs a token that lets the guest view and cancel the
+// appointment and emails them a link containing it. For an unconfirmed
+// booking the link also confirms it, so the token must then only reach the
+// guest by email. The token stays valid until the configured grace period
+// after the appointment ends.
+func (s *GuestService) IssueManageToken(ctx context.Context, guest *models.Guest, appointment *models.Appointment) (string, error) {
+	claims := &guestBookingClaims{
+		AppointmentID: appointment.ID,
+		GuestID:       guest.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{guestBookingAudience},
+			ExpiresAt: jwt.NewNumericDate(appointment.EndTime.Add(s.config.GuestManageTokenGrace)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", err
+	}
+
+	link := fmt.Sprintf("%s/bookings/manage?token=%s", s.config.FrontendURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nYour booking request for %s has been received. You can view or cancel it using the link below.\n\n%s",
+		guest.Name, appointment.StartTime.Format("Mon 2 Jan 2006 15:04"), link)
+	if appointment.Status == models.StatusUnconfirmed {
+		body = fmt.Sprintf("Hi %s,\n\nPlease confirm your booking request for %s by opening the link below. It is not sent to the master until you do, and the time is not reserved for you until then.\n\n%s",
+			guest.Name, appointment.StartTime.Format("Mon 2 Jan 2006 15:04"), link)
+	}
+	if err := s.notifier.Send(ctx, notify.Message{
+		To:      guest.Email,
+		Subject: "Your Timebook booking",
+		Body:    body,
+	}); err != nil {
+		// The token is also returned to the guest directly
+		log.Printf("guest booking: failed to email guest %d: %v", guest.ID, err)
+	}
+
+	return token, nil
+}
+
+// GetAppointment returns the appointment a manage-booking token was issued for
+func (s *GuestService) GetAppointment(ctx context.Context, token string) (*models.Appointment, error) {
+	claims, err := s.parseManageToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.guestAppointment(ctx, nil, claims, false)
+}
+
+// ConfirmAppointment confirms the guest's email for an unconfirmed booking,
+// which makes it a pending request for the master. The time must still be
+// free. Confirming again is harmless.
+func (s *GuestService) ConfirmAppointment(ctx context.Context, token string) (*models.Appointment, error) {
+	claims, err := s.parseManageToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		appointment, err := s.guestAppointment(ctx, tx, claims, true)
+		if err != nil {
+			return nil, err
+		}
+		switch appointment.Status {
+		case models.StatusUnconfirmed:
+		case models.StatusPending, models.StatusConfirmed:
+			return appointment, nil
+		default:
+			return nil, ErrGuestNotConfirmable
+		}
+
+		// Lock the master so the time cannot be booked concurrently
+		master, err := s.masterRepo.GetByIDForUpdate(ctx, tx, appointment.MasterID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.availability.CheckFree(ctx, tx, master, appointment.StartTime, appointment.EndTime, Ignore{AppointmentID: appointment.ID}); err != nil {
+			return nil, err
+		}
+
+		if err := transitionAppointment(ctx, tx, s.appointmentRepo, s.transitionRepo, appointment, models.StatusPending, nil, guestConfirmedReason); err != nil {
+			if errors.Is(err, repositories.ErrAppointmentOverlap) {
+				return nil, ErrTimeSlotConflict
+			}
+			return nil, err
+		}
+		if err := s.timeslotRepo.BookServiceSlot(ctx, tx, appointment.MasterID, appointment.ServiceID, appointment.StartTime, appointment.EndTime); err != nil {
+			return nil, err
+		}
+		return s.appointmentRepo.GetByID(ctx, tx, appointment.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Appointment), nil
+}
+
+// CancelAppointment cancels the appointment a manage-booking token was issued
+// for and frees its time slot. The master's cancellation policy applies.
+func (s *GuestService) CancelAppointment(ctx context.Context, token, reason string) (*models.Appointment, error) {
+	claims, err := s.parseManageToken(token)
+	if err != nil {
+		return nil, err
+	}
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxCancellationReasonLength {
+		return nil, ErrCancellationReason
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		appointment, err := s.guestAppointment(ctx, tx, claims, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCancellable(appointment, time.Now()); err != nil {
+			return nil, err
+		}
+
+		if err := cancelAppointment(ctx, tx, s.appointmentRepo, s.timeslotRepo, s.transitionRepo, appointment, nil, reason); err != nil {
+			return nil, err
+		}
+
+		return s.appointmentRepo.GetByID(ctx, tx, appointment.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Appointment), nil
+}
+
+// parseManageToken verifies a manage-booking token
+func (s *GuestService) parseManageToken(token string) (*guestBookingClaims, error) {
+	claims := &guestBookingClaims{}
+	if _, err := s.keys.Parse(token, claims, jwt.WithAudience(guestBookingAudience)); err != nil {
+		return nil, ErrInvalidManageToken
+	}
+	return claims, nil
+}
+
+// guestAppointment loads the appointment named by the token, checking it
+// still belongs to the guest the token was issued to. With forUpdate the row
+// stays locked until tx ends, so its status cannot change underneath.
+func (s *GuestService) guestAppointment(ctx context.Context, tx *gorm.DB, claims *guestBookingClaims, forUpdate bool) (*models.Appointment, error) {
+	load := s.appointmentRepo.GetByID
+	if forUpdate {
+		load = s.appointmentRepo.GetByIDForUpdate
+	}
+	appointment, err := load(ctx, tx, claims.AppointmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidManageToken
+		}
+		return nil, err
+	}
+	if appointment.GuestID == nil || *appointment.GuestID != claims.GuestID {
+		return nil, ErrInvalidManageToken
+	}
+	return appointment, nil
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/jwtkeys"
+	"github.com/timebook/backend/internal/middleware"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// Impersonation errors
+var (
+	ErrImpersonateSelf        = apperrors.New("IMPERSONATE_SELF", "You cannot impersonate yourself", http.StatusBadRequest)
+	ErrImpersonateAdmin       = apperrors.New("IMPERSONATE_ADMIN", "Admins cannot be impersonated", http.StatusForbidden)
+	ErrImpersonationReason    = apperrors.New("VALIDATION_ERROR", "A reason for impersonating the user is required", http.StatusBadRequest)
+	ErrImpersonationNoSession = apperrors.New("IMPERSONATION_REQUIRES_SESSION", "Impersonation must be started from a signed-in session", http.StatusForbidden)
+)
+
+// ImpersonationToken is an access token that lets an admin act as another user
+type ImpersonationToken struct {
+	Impersonation *models.Impersonation
+	AccessToken   string
+	Banner        string
+	User          *models.User
+}
+
+// ImpersonationService lets admins act as another user for support, keeping
+// an audit trail of everything they do
+type ImpersonationService struct {
+	impersonationRepo repositories.ImpersonationRepository
+	userRepo          repositories.UserRepository
+	auditRepo         repositories.AuditRepository
+	txManager         *transaction.Manager
+	keys              *jwtkeys.KeySet
+	config            *config.Config
+}
+
+// NewImpersonationService creates a new impersonation service
+func NewImpersonationService(
+	impersonationRepo repositories.ImpersonationRepository,
+	userRepo repositories.UserRepository,
+	auditRepo repositories.AuditRepository,
+	txManager *transaction.Manager,
+	keys *jwtkeys.KeySet,
+	cfg *config.Config,
+) *ImpersonationService {
+	return &ImpersonationService{
+		impersonationRepo: impersonationRepo,
+		userRepo:          userRepo,
+		auditRepo:         auditRepo,
+		txManager:         txManager,
+		keys:              keys,
+		config:            cfg,
+	}
+}
+
+// Start opens an impersonation of the user and returns a short-lived access
+// token for it. The token is tied to the admin's session: signing that
+// session out ends the impersonation too.
+func (s *ImpersonationService) Start(ctx context.Context, adminID uint, adminSessionID string, userID uint, reason, ip string) (*ImpersonationToken, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrImpersonationReason
+	}
+	if adminSessionID == "" {
+		return nil, ErrImpersonationNoSession
+	}
+	if adminID == userID {
+		return nil, ErrImpersonateSelf
+	}
+
+	admin, err := s.userRepo.GetByID(ctx, nil, adminID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.userRepo.GetByIDForUpdate(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if user.HasRole(models.RoleAdmin) {
+			return nil, ErrImpersonateAdmin
+		}
+
+		impersonation := &models.Impersonation{
+			AdminID:        adminID,
+			AdminSessionID: adminSessionID,
+			UserID:         user.ID,
+			Reason:         reason,
+			IPAddress:      ip,
+			ExpiresAt:      time.Now().Add(s.config.ImpersonationTTL),
+		}
+		if err := s.impersonationRepo.Create(ctx, tx, impersonation); err != nil {
+			return nil, err
+		}
+
+		if err := s.auditRepo.Create(ctx, tx, &models.AuditLog{
+			ActorID:    &adminID,
+			Action:     models.AuditImpersonationStarted,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+			IPAddress:  ip,
+			Details:    fmt.Sprintf("impersonation=%d reason=%q", impersonation.ID, reason),
+		}); err != nil {
+			return nil, err
+		}
+
+		return &ImpersonationToken{Impersonation: impersonation, User: user}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	token := result.(*ImpersonationToken)
+
+	token.Banner = fmt.Sprintf("%s is viewing Timebook as %s (%s). Everything done here is recorded.",
+		admin.Name, token.User.Name, token.User.Email)
+	claims := &middleware.Claims{
+		UserID:          token.User.ID,
+		Email:           token.User.Email,
+		Role:            string(token.User.Role),
+		Roles:           roleNames(token.User),
+		ImpersonationID: token.Impersonation.ID,
+		ImpersonatorID:  adminID,
+		Banner:          token.Banner,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(token.Impersonation.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(token.Impersonation.CreatedAt),
+		},
+	}
+	token.AccessToken, err = s.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// End closes an impersonation the admin started, invalidating its token.
+// Ending one that is already over is a no-op.
+func (s *ImpersonationService) End(ctx context.Context, adminID, impersonationID uint, ip string) error {
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		impersonation, err := s.impersonationRepo.GetByIDForUpdate(ctx, tx, impersonationID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if impersonation.AdminID != adminID {
+			return nil, apperrors.ErrNotFound
+		}
+		if impersonation.EndedAt != nil {
+			return nil, nil
+		}
+
+		now := time.Now()
+		impersonation.EndedAt = &now
+		if err := s.impersonationRepo.Update(ctx, tx, impersonation); err != nil {
+			return nil, err
+		}
+
+		return nil, s.auditRepo.Create(ctx, tx, &models.AuditLog{
+			ActorID:    &adminID,
+			Action:     models.AuditImpersonationEnded,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(impersonation.UserID), 10),
+			IPAddress:  ip,
+			Details:    fmt.Sprintf("impersonation=%d", impersonation.ID),
+		})
+	})
+	return err
+}
+
+// RecordRequest writes an audit entry for a request made while impersonating.
+// The entry is attributed to the admin, with the impersonated user as target.
+func (s *ImpersonationService) RecordRequest(ctx context.Context, claims *middleware.Claims, method, path, ip string) error {
+	return s.auditRepo.Create(ctx, nil, &models.AuditLog{
+		ActorID:    &claims.ImpersonatorID,
+		Action:     models.AuditImpersonationRequest,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(claims.UserID), 10),
+		IPAddress:  ip,
+		Details:    fmt.Sprintf("impersonation=%d %s %s", claims.ImpersonationID, method, path),
+	})
+}
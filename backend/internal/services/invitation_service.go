@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/jwtkeys"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/password"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// inviteAudience keeps invite tokens from being mistaken for access tokens
+const inviteAudience = "timebook-invite"
+
+// Invitation errors
+var (
+	ErrInvalidInvitation = apperrors.New("INVALID_INVITATION", "Invalid or expired invitation", http.StatusBadRequest)
+	ErrInvalidRole       = apperrors.New("INVALID_ROLE", "Role must be one of user, master or admin", http.StatusBadRequest)
+	ErrEmailTaken        = apperrors.New("EMAIL_TAKEN", "An account with this email already exists", http.StatusConflict)
+)
+
+// inviteClaims are the claims carried by a signed invite token
+type inviteClaims struct {
+	InvitationID uint `json:"invitation_id"`
+	jwt.RegisteredClaims
+}
+
+// AcceptInvitationInput holds the account details supplied by the invitee
+type AcceptInvitationInput struct {
+	Token    string
+	Password string
+	Name     string
+	Phone    string
+}
+
+// InvitationService handles admin-issued invitations
+type InvitationService struct {
+	invitationRepo repositories.InvitationRepository
+	userRepo       repositories.UserRepository
+	masterRepo     repositories.MasterRepository
+	passwords      *password.Policy
+	txManager      *transaction.Manager
+	keys           *jwtkeys.KeySet
+	config         *config.Config
+}
+
+// NewInvitationService creates a new invitation service
+func NewInvitationService(
+	invitationRepo repositories.InvitationRepository,
+	userRepo repositories.UserRepository,
+	masterRepo repositories.MasterRepository,
+	passwords *password.Policy,
+	txManager *transaction.Manager,
+	keys *jwtkeys.KeySet,
+	cfg *config.Config,
+) *InvitationService {
+	return &InvitationService{
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		masterRepo:     masterRepo,
+		passwords:      passwords,
+		txManager:      txManager,
+		keys:           keys,
+		config:         cfg,
+	}
+}
+
+// CreateInvitation records a new invitation and returns it together with the
+// signed invite token. The token is only available at creation time. The
+// email is stored lower-cased so one address cannot be invited twice under
+// different spellings.
+func (s *InvitationService) CreateInvitation(ctx context.Context, invitedByID uint, email string, role models.UserRole) (*models.Invitation, string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, "", apperrors.New("VALIDATION_ERROR", "Email is required", http.StatusBadRequest)
+	}
+	if !validEmail(email) {
+		return nil, "", ErrInvalidEmail
+	}
+	email = normalizeEmail(email)
+	if role != models.RoleUser && role != models.RoleMaster && role != models.RoleAdmin {
+		return nil, "", ErrInvalidRole
+	}
+
+	if taken, err := s.userRepo.ExistsByEmailFold(ctx, nil, email); err != nil {
+		return nil, "", err
+	} else if taken {
+		return nil, "", ErrEmailTaken
+	}
+
+	nonce, err := newOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation := &models.Invitation{
+		Email:       email,
+		Role:        role,
+		InvitedByID: invitedByID,
+		NonceHash:   hashToken(nonce),
+		ExpiresAt:   time.Now().Add(s.config.InviteTTL),
+	}
+	if err := s.invitationRepo.Create(ctx, nil, invitation); err != nil {
+		return nil, "", err
+	}
+
+	claims := &inviteClaims{
+		InvitationID: invitation.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        nonce,
+			Audience:  jwt.ClaimStrings{inviteAudience},
+			ExpiresAt: jwt.NewNumericDate(invitation.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(invitation.CreatedAt),
+		},
+	}
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return invitation, token, nil
+}
+
+// ListPending returns invitations that can still be accepted
+func (s *InvitationService) ListPending(ctx context.Context) ([]*models.Invitation, error) {
+	return s.invitationRepo.ListPending(ctx, nil)
+}
+
+// RevokeInvitation prevents a pending invitation from being accepted
+func (s *InvitationService) RevokeInvitation(ctx context.Context, invitationID uint) error {
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		invitation, err := s.invitationRepo.GetByIDForUpdate(ctx, tx, invitationID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if invitation.AcceptedAt != nil {
+			return nil, apperrors.New("INVITATION_ACCEPTED", "Invitation has already been accepted", http.StatusConflict)
+		}
+		if invitation.RevokedAt != nil {
+			return nil, nil
+		}
+
+		now := time.Now()
+		invitation.RevokedAt = &now
+		return nil, s.invitationRepo.Update(ctx, tx, invitation)
+	})
+	return err
+}
+
+// AcceptInvitation consumes an invite token and creates the invited account
+// with the invitation's email and role
+func (s *InvitationService) AcceptInvitation(ctx context.Context, input AcceptInvitationInput) (*models.User, error) {
+	claims := &inviteClaims{}
+	_, err := s.keys.Parse(input.Token, claims, jwt.WithAudience(inviteAudience))
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+	if err := s.passwords.Validate(input.Password); err != nil {
+		return nil, err
+	}
+	phone, err := normalizePhone(input.Phone, s.config.PhoneDefaultCountryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		invitation, err := s.invitationRepo.GetByIDForUpdate(ctx, tx, claims.InvitationID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidInvitation
+			}
+			return nil, err
+		}
+		if invitation.NonceHash != hashToken(claims.ID) ||
+			invitation.AcceptedAt != nil ||
+			invitation.RevokedAt != nil ||
+			time.Now().After(invitation.ExpiresAt) {
+			return nil, ErrInvalidInvitation
+		}
+
+		if taken, err := s.userRepo.ExistsByEmailFold(ctx, tx, invitation.Email); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, ErrEmailTaken
+		}
+
+		if err := checkPhoneFree(ctx, tx, s.userRepo, phone, 0); err != nil {
+			return nil, err
+		}
+
+		hashedPassword, err := s.passwords.Hash(input.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		user := &models.User{
+			Email:    invitation.Email,
+			Password: hashedPassword,
+			Name:     input.Name,
+			Phone:    phone,
+			Role:     invitation.Role,
+		}
+		if err := s.userRepo.Create(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		if user.Role == models.RoleMaster {
+			if err := s.masterRepo.Create(ctx, tx, &models.MasterProfile{UserID: user.ID}); err != nil {
+				return nil, err
+			}
+		}
+
+		now := time.Now()
+		invitation.AcceptedAt = &now
+		invitation.AcceptedUserID = &user.ID
+		if err := s.invitationRepo.Update(ctx, tx, invitation); err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
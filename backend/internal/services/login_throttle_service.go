@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// LoginThrottleService tracks failed logins per account and per client
+// address, applies exponential backoff between attempts on an account and
+// locks accounts and addresses out after too many failures
+type LoginThrottleService struct {
+	throttleRepo repositories.LoginThrottleRepository
+	auditRepo    repositories.AuditRepository
+	userRepo     repositories.UserRepository
+	txManager    *transaction.Manager
+	config       *config.Config
+}
+
+// NewLoginThrottleService creates a new login throttle service
+func NewLoginThrottleService(
+	throttleRepo repositories.LoginThrottleRepository,
+	auditRepo repositories.AuditRepository,
+	userRepo repositories.UserRepository,
+	txManager *transaction.Manager,
+	cfg *config.Config,
+) *LoginThrottleService {
+	return &LoginThrottleService{
+		throttleRepo: throttleRepo,
+		auditRepo:    auditRepo,
+		userRepo:     userRepo,
+		txManager:    txManager,
+		config:       cfg,
+	}
+}
+
+// Check returns how long the caller must wait before another login attempt
+// for this email from this address is allowed. Zero means go ahead.
+func (s *LoginThrottleService) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now()
+
+	account, err := s.throttleRepo.Get(ctx, nil, models.ThrottleScopeAccount, normalizeEmail(email))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	wait := time.Duration(0)
+	if err == nil {
+		wait = s.retryAfter(account, now, true)
+	}
+
+	if ip != "" {
+		address, err := s.throttleRepo.Get(ctx, nil, models.ThrottleScopeIP, ip)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		if err == nil {
+			if ipWait := s.retryAfter(address, now, false); ipWait > wait {
+				wait = ipWait
+			}
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordFailure counts a failed login against the account and the address,
+// locking either out once it reaches its limit
+func (s *LoginThrottleService) RecordFailure(ctx context.Context, email, ip string) error {
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		if err := s.recordFailure(ctx, tx, models.ThrottleScopeAccount, normalizeEmail(email), s.config.LoginMaxAttempts, ip); err != nil {
+			return nil, err
+		}
+		if ip == "" {
+			return nil, nil
+		}
+		return nil, s.recordFailure(ctx, tx, models.ThrottleScopeIP, ip, s.config.LoginMaxAttemptsPerIP, ip)
+	})
+	return err
+}
+
+// RecordSuccess clears the failure count for the account. The address
+// counter is kept so that one valid account cannot mask guessing on others.
+func (s *LoginThrottleService) RecordSuccess(ctx context.Context, email string) error {
+	return s.throttleRepo.Delete(ctx, nil, models.ThrottleScopeAccount, normalizeEmail(email))
+}
+
+// RecordFirstFactor clears the failure count after a correct password,
+// unless the account still has a second factor to pass. Wrong two-factor
+// codes count against the same counter, so clearing it before the second
+// factor would let anyone holding the password keep guessing codes.
+func (s *LoginThrottleService) RecordFirstFactor(ctx context.Context, user *models.User) error {
+	if user.TwoFactorEnabled() {
+		return nil
+	}
+	return s.RecordSuccess(ctx, user.Email)
+}
+
+// Unlock lets an admin lift a lockout on a user's account
+func (s *LoginThrottleService) Unlock(ctx context.Context, adminID, userID uint, ip string) error {
+	user, err := s.userRepo.GetByID(ctx, nil, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound
+		}
+		return err
+	}
+
+	_, err = s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		if err := s.throttleRepo.Delete(ctx, tx, models.ThrottleScopeAccount, normalizeEmail(user.Email)); err != nil {
+			return nil, err
+		}
+		return nil, s.auditRepo.Create(ctx, tx, &models.AuditLog{
+			ActorID:    &adminID,
+			Action:     models.AuditLoginUnlocked,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+			IPAddress:  ip,
+		})
+	})
+	return err
+}
+
+func (s *LoginThrottleService) recordFailure(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string, maxAttempts int, ip string) error {
+	throttle, err := s.throttleRepo.GetOrCreateForUpdate(ctx, tx, scope, key)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if s.isStale(throttle, now) {
+		throttle.FailedAttempts = 0
+		throttle.LockedUntil = nil
+	}
+
+	throttle.FailedAttempts++
+	throttle.LastFailedAt = &now
+
+	if maxAttempts > 0 && throttle.FailedAttempts >= maxAttempts && throttle.LockedUntil == nil {
+		lockedUntil := now.Add(s.config.LoginLockoutDuration)
+		throttle.LockedUntil = &lockedUntil
+
+		if err := s.auditRepo.Create(ctx, tx, &models.AuditLog{
+			Action:     models.AuditLoginLocked,
+			TargetType: string(scope),
+			TargetID:   key,
+			IPAddress:  ip,
+			Details:    fmt.Sprintf("%d failed attempts; locked until %s", throttle.FailedAttempts, lockedUntil.UTC().Format(time.RFC3339)),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return s.throttleRepo.Update(ctx, tx, throttle)
+}
+
+// isStale reports whether a record's failures should be forgotten: its
+// lockout has ended, or the last failure is outside the attempt window
+func (s *LoginThrottleService) isStale(throttle *models.LoginThrottle, now time.Time) bool {
+	if throttle.LockedUntil != nil {
+		return !now.Before(*throttle.LockedUntil)
+	}
+	return throttle.LastFailedAt == nil || now.Sub(*throttle.LastFailedAt) > s.config.LoginAttemptWindow
+}
+
+// retryAfter computes the remaining lockout or backoff delay for a record
+func (s *LoginThrottleService) retryAfter(throttle *models.LoginThrottle, now time.Time, backoff bool) time.Duration {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now)
+	}
+	if !backoff || throttle.FailedAttempts == 0 || s.isStale(throttle, now) {
+		return 0
+	}
+
+	// Double the delay with every consecutive failure, capped at the lockout duration
+	delay := s.config.LoginBackoffBase
+	for i := 1; i < throttle.FailedAttempts && delay < s.config.LoginLockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > s.config.LoginLockoutDuration {
+		delay = s.config.LoginLockoutDuration
+	}
+
+	if next := throttle.LastFailedAt.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// normalizeEmail returns the canonical form of an email used as a lookup key
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	"github.com/timebook/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// memoryThrottleRepo keeps login throttles in a map
+type memoryThrottleRepo struct {
+	rows map[models.ThrottleScope]map[string]*models.LoginThrottle
+}
+
+func newMemoryThrottleRepo() *memoryThrottleRepo {
+	return &memoryThrottleRepo{rows: map[models.ThrottleScope]map[string]*models.LoginThrottle{}}
+}
+
+func (r *memoryThrottleRepo) Get(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) (*models.LoginThrottle, error) {
+	if throttle, ok := r.rows[scope][key]; ok {
+		return throttle, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryThrottleRepo) GetOrCreateForUpdate(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) (*models.LoginThrottle, error) {
+	if throttle, ok := r.rows[scope][key]; ok {
+		return throttle, nil
+	}
+	throttle := &models.LoginThrottle{Scope: scope, Key: key}
+	return throttle, r.Update(ctx, tx, throttle)
+}
+
+func (r *memoryThrottleRepo) Update(ctx context.Context, tx *gorm.DB, throttle *models.LoginThrottle) error {
+	if r.rows[throttle.Scope] == nil {
+		r.rows[throttle.Scope] = map[string]*models.LoginThrottle{}
+	}
+	r.rows[throttle.Scope][throttle.Key] = throttle
+	return nil
+}
+
+func (r *memoryThrottleRepo) Delete(ctx context.Context, tx *gorm.DB, scope models.ThrottleScope, key string) error {
+	delete(r.rows[scope], key)
+	return nil
+}
+
+func TestRecordFirstFactorKeepsTwoFactorFailures(t *testing.T) {
+	now := time.Now()
+	enabledAt := now.Add(-24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		wantWait bool
+	}{
+		{"two-factor account stays locked", &models.User{Email: "Ann@Example.com", TOTPEnabledAt: &enabledAt}, true},
+		{"password-only account is cleared", &models.User{Email: "Ann@Example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The account was locked by wrong two-factor codes
+			repo := newMemoryThrottleRepo()
+			lockedUntil := now.Add(10 * time.Minute)
+			repo.Update(context.Background(), nil, &models.LoginThrottle{
+				Scope:          models.ThrottleScopeAccount,
+				Key:            "ann@example.com",
+				FailedAttempts: 5,
+				LastFailedAt:   &now,
+				LockedUntil:    &lockedUntil,
+			})
+			s := NewLoginThrottleService(repo, nil, nil, nil, &config.Config{
+				LoginAttemptWindow:   15 * time.Minute,
+				LoginBackoffBase:     time.Second,
+				LoginLockoutDuration: 15 * time.Minute,
+			})
+
+			if err := s.RecordFirstFactor(context.Background(), tt.user); err != nil {
+				t.Fatal(err)
+			}
+			wait, err := s.Check(context.Background(), "ann@example.com", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotWait := wait > 0; gotWait != tt.wantWait {
+				t.Errorf("Check wait = %s after a correct password, want locked %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
@@ -2,13 +2,53 @@ package services
 
 import (
 	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	apperrors "github.com/timebook/backend/internal/errors"
 	"github.com/timebook/backend/internal/models"
 	"github.com/timebook/backend/internal/repositories"
 	"github.com/timebook/backend/internal/transaction"
 	"gorm.io/gorm"
 )
 
+// Master profile limits
+const (
+	maxBioLength       = 2000
+	maxSpecialtyLength = 255
+	maxExperience      = 80
+	maxNoticeHours     = 720
+)
+
+// Master profile errors
+var (
+	ErrInvalidBio        = apperrors.New("VALIDATION_ERROR", "Bio must be at most 2000 characters", http.StatusBadRequest)
+	ErrInvalidSpecialty  = apperrors.New("VALIDATION_ERROR", "Specialty must be at most 255 characters", http.StatusBadRequest)
+	ErrInvalidExperience = apperrors.New("VALIDATION_ERROR", "Experience must be between 0 and 80 years", http.StatusBadRequest)
+	ErrInvalidNotice     = apperrors.New("VALIDATION_ERROR", "Cancellation notice must be between 0 and 720 hours", http.StatusBadRequest)
+	ErrInvalidTimeZone   = apperrors.New("VALIDATION_ERROR", "Time zone must be an IANA name such as Europe/London", http.StatusBadRequest)
+	ErrInvalidWorkday    = apperrors.New("VALIDATION_ERROR", "Working hours must start before they end, between 0 and 24", http.StatusBadRequest)
+)
+
+// MasterProfileUpdate holds the master profile fields to change; nil fields
+// are left as they are
+type MasterProfileUpdate struct {
+	Bio        *string
+	Specialty  *string
+	Experience *int
+
+	// CancellationNoticeHours is the cancellation policy: how many hours
+	// before the start clients can cancel at the latest
+	CancellationNoticeHours *int
+
+	// Working hours in the master's time zone
+	TimeZone         *string
+	WorkdayStartHour *int
+	WorkdayEndHour   *int
+}
+
 // MasterService handles business logic for master operations
 type MasterService struct {
 	masterRepo repositories.MasterRepository
@@ -36,3 +76,70 @@ func (s *MasterService) GetOrCreateMasterProfile(ctx context.Context, userID uin
 	}
 	return profile, err
 }
+
+// UpdateProfile changes the master's bio, specialty, years of experience and
+// cancellation policy, creating the profile first if it does not exist yet
+func (s *MasterService) UpdateProfile(ctx context.Context, userID uint, update MasterProfileUpdate) (*models.MasterProfile, error) {
+	if update.Bio != nil {
+		bio := strings.TrimSpace(*update.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return nil, ErrInvalidBio
+		}
+		update.Bio = &bio
+	}
+	if update.Specialty != nil {
+		specialty := strings.TrimSpace(*update.Specialty)
+		if utf8.RuneCountInString(specialty) > maxSpecialtyLength {
+			return nil, ErrInvalidSpecialty
+		}
+		update.Specialty = &specialty
+	}
+	if update.Experience != nil && (*update.Experience < 0 || *update.Experience > maxExperience) {
+		return nil, ErrInvalidExperience
+	}
+	if update.CancellationNoticeHours != nil && (*update.CancellationNoticeHours < 0 || *update.CancellationNoticeHours > maxNoticeHours) {
+		return nil, ErrInvalidNotice
+	}
+	if update.TimeZone != nil {
+		timeZone := strings.TrimSpace(*update.TimeZone)
+		if _, err := time.LoadLocation(timeZone); err != nil || timeZone == "" || timeZone == "Local" {
+			return nil, ErrInvalidTimeZone
+		}
+		update.TimeZone = &timeZone
+	}
+
+	profile, err := s.GetOrCreateMasterProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Bio != nil {
+		profile.Bio = *update.Bio
+	}
+	if update.Specialty != nil {
+		profile.Specialty = *update.Specialty
+	}
+	if update.Experience != nil {
+		profile.Experience = *update.Experience
+	}
+	if update.CancellationNoticeHours != nil {
+		profile.CancellationNoticeHours = *update.CancellationNoticeHours
+	}
+	if update.TimeZone != nil {
+		profile.TimeZone = *update.TimeZone
+	}
+	if update.WorkdayStartHour != nil {
+		profile.WorkdayStartHour = *update.WorkdayStartHour
+	}
+	if update.WorkdayEndHour != nil {
+		profile.WorkdayEndHour = *update.WorkdayEndHour
+	}
+	// Checked after merging, as either end may be left unchanged
+	if profile.WorkdayStartHour < 0 || profile.WorkdayEndHour > 24 || profile.WorkdayStartHour >= profile.WorkdayEndHour {
+		return nil, ErrInvalidWorkday
+	}
+	if err := s.masterRepo.Update(ctx, nil, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
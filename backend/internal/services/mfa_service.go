@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/jwtkeys"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/totp"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+const (
+	// mfaAudience keeps MFA challenge tokens from being used as access tokens
+	mfaAudience = "timebook-mfa"
+	// totpIssuer is shown next to the account in authenticator apps
+	totpIssuer = "Timebook"
+	// recoveryCodeCount is the number of recovery codes issued on enrollment
+	recoveryCodeCount = 10
+)
+
+// Two-factor authentication errors
+var (
+	ErrTwoFactorNotAllowed  = apperrors.New("TWO_FACTOR_NOT_ALLOWED", "Two-factor authentication is only available for masters and admins", http.StatusForbidden)
+	ErrTwoFactorEnabled     = apperrors.New("TWO_FACTOR_ENABLED", "Two-factor authentication is already enabled", http.StatusConflict)
+	ErrTwoFactorNotEnrolled = apperrors.New("TWO_FACTOR_NOT_ENROLLED", "Two-factor enrollment has not been started", http.StatusBadRequest)
+	ErrTwoFactorNotEnabled  = apperrors.New("TWO_FACTOR_NOT_ENABLED", "Two-factor authentication is not enabled", http.StatusBadRequest)
+	ErrInvalidTwoFactorCode = apperrors.New("INVALID_TWO_FACTOR_CODE", "Invalid authentication code", http.StatusUnauthorized)
+	ErrInvalidMFAChallenge  = apperrors.New("INVALID_MFA_CHALLENGE", "Invalid or expired MFA challenge", http.StatusUnauthorized)
+)
+
+// TwoFactorEnrollment is returned when a user starts enrolling an authenticator
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFAService handles TOTP enrollment, recovery codes and the second step of login
+type MFAService struct {
+	userRepo         repositories.UserRepository
+	recoveryCodeRepo repositories.RecoveryCodeRepository
+	throttleRepo     repositories.LoginThrottleRepository
+	txManager        *transaction.Manager
+	keys             *jwtkeys.KeySet
+	config           *config.Config
+}
+
+// NewMFAService creates a new MFA service
+func NewMFAService(
+	userRepo repositories.UserRepository,
+	recoveryCodeRepo repositories.RecoveryCodeRepository,
+	throttleRepo repositories.LoginThrottleRepository,
+	txManager *transaction.Manager,
+	keys *jwtkeys.KeySet,
+	cfg *config.Config,
+) *MFAService {
+	return &MFAService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		throttleRepo:     throttleRepo,
+		txManager:        txManager,
+		keys:             keys,
+		config:           cfg,
+	}
+}
+
+// Enroll generates a new TOTP secret for the user. It does not take effect
+// until confirmed with Activate.
+func (s *MFAService) Enroll(ctx context.Context, userID uint) (*TwoFactorEnrollment, error) {
+	user, err := s.getUser(ctx, nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasRole(models.RoleMaster) && !user.HasRole(models.RoleAdmin) {
+		return nil, ErrTwoFactorNotAllowed
+	}
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	if err := s.userRepo.Update(ctx, nil, user); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// Activate confirms enrollment with a code from the authenticator, enables
+// two-factor authentication and returns a fresh set of recovery codes
+func (s *MFAService) Activate(ctx context.Context, userID uint, code string) ([]string, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.getUser(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if user.TwoFactorEnabled() {
+			return nil, ErrTwoFactorEnabled
+		}
+		if user.TOTPSecret == "" {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1)
+		if !ok {
+			return nil, ErrInvalidTwoFactorCode
+		}
+
+		now := time.Now()
+		user.TOTPEnabledAt = &now
+		user.TOTPLastStep = step
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		return s.replaceRecoveryCodes(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// Disable turns two-factor authentication off after checking a current
+// TOTP or recovery code
+func (s *MFAService) Disable(ctx context.Context, userID uint, code string) error {
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.getUser(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !user.TwoFactorEnabled() {
+			return nil, ErrTwoFactorNotEnabled
+		}
+		if err := s.verifyCode(ctx, tx, user, code); err != nil {
+			return nil, err
+		}
+
+		user.TOTPSecret = ""
+		user.TOTPEnabledAt = nil
+		user.TOTPLastStep = 0
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+		return nil, s.recoveryCodeRepo.DeleteForUser(ctx, tx, user.ID)
+	})
+	return err
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a current TOTP or recovery code
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.getUser(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !user.TwoFactorEnabled() {
+			return nil, ErrTwoFactorNotEnabled
+		}
+		if err := s.verifyCode(ctx, tx, user, code); err != nil {
+			return nil, err
+		}
+		return s.replaceRecoveryCodes(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// NewChallenge returns a short-lived token proving the user passed the
+// password step of login. It is exchanged for real tokens by CompleteChallenge.
+func (s *MFAService) NewChallenge(user *models.User) (string, time.Time, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.config.MFAChallengeTTL)
+
+	claims := jwt.RegisteredClaims{
+		ID:        id,
+		Subject:   strconv.FormatUint(uint64(user.ID), 10),
+		Audience:  jwt.ClaimStrings{mfaAudience},
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ChallengeUser returns the user an MFA challenge token was issued to
+func (s *MFAService) ChallengeUser(ctx context.Context, challenge string) (*models.User, error) {
+	_, userID, err := s.parseChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, nil, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// CompleteChallenge checks the second factor for a challenge issued by
+// NewChallenge and returns the authenticated user. A challenge can be
+// completed once and is burned after too many wrong codes.
+func (s *MFAService) CompleteChallenge(ctx context.Context, challenge, code string) (*models.User, error) {
+	claims, userID, err := s.parseChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		attempts, err := s.throttleRepo.GetOrCreateForUpdate(ctx, tx, models.ThrottleScopeMFAChallenge, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if attempts.LockedUntil != nil {
+			return nil, ErrInvalidMFAChallenge
+		}
+
+		user, err := s.getUser(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrNotFound) {
+				return nil, ErrInvalidMFAChallenge
+			}
+			return nil, err
+		}
+		if !user.TwoFactorEnabled() {
+			return nil, ErrInvalidMFAChallenge
+		}
+
+		// The attempt is counted even when the code is wrong, so the
+		// transaction must commit either way
+		now := time.Now()
+		verifyErr := s.verifyCode(ctx, tx, user, code)
+		if verifyErr != nil && !errors.Is(verifyErr, ErrInvalidTwoFactorCode) {
+			return nil, verifyErr
+		}
+		if verifyErr != nil {
+			attempts.FailedAttempts++
+			attempts.LastFailedAt = &now
+		}
+		if verifyErr == nil || attempts.FailedAttempts >= s.config.MFAChallengeMaxAttempts {
+			expiresAt := claims.ExpiresAt.Time
+			attempts.LockedUntil = &expiresAt
+		}
+		if err := s.throttleRepo.Update(ctx, tx, attempts); err != nil {
+			return nil, err
+		}
+
+		if verifyErr != nil {
+			return (*models.User)(nil), nil
+		}
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	user := result.(*models.User)
+	if user == nil {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	return user, nil
+}
+
+// parseChallenge checks an MFA challenge token and returns its claims and
+// the ID of the user it was issued to
+func (s *MFAService) parseChallenge(challenge string) (*jwt.RegisteredClaims, uint, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := s.keys.Parse(challenge, claims, jwt.WithAudience(mfaAudience), jwt.WithExpirationRequired())
+	if err != nil || claims.ID == "" {
+		return nil, 0, ErrInvalidMFAChallenge
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return nil, 0, ErrInvalidMFAChallenge
+	}
+	return claims, uint(userID), nil
+}
+
+// verifyCode accepts either a TOTP code that has not been used before or an
+// unused recovery code, consuming it. The user row must be locked by tx.
+func (s *MFAService) verifyCode(ctx context.Context, tx *gorm.DB, user *models.User, code string) error {
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1); ok {
+		if step <= user.TOTPLastStep {
+			return ErrInvalidTwoFactorCode
+		}
+		user.TOTPLastStep = step
+		return s.userRepo.Update(ctx, tx, user)
+	}
+
+	recovery, err := s.recoveryCodeRepo.GetUnusedForUpdate(ctx, tx, user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidTwoFactorCode
+		}
+		return err
+	}
+	now := time.Now()
+	recovery.UsedAt = &now
+	return s.recoveryCodeRepo.Update(ctx, tx, recovery)
+}
+
+// replaceRecoveryCodes generates new recovery codes, stores their hashes
+// and returns the plain codes for display
+func (s *MFAService) replaceRecoveryCodes(ctx context.Context, tx *gorm.DB, userID uint) ([]string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	stored := make([]*models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, code)
+		stored = append(stored, &models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceForUser(ctx, tx, userID, stored); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+func (s *MFAService) getUser(ctx context.Context, tx *gorm.DB, userID uint) (*models.User, error) {
+	var (
+		user *models.User
+		err  error
+	)
+	if tx != nil {
+		user, err = s.userRepo.GetByIDForUpdate(ctx, tx, userID)
+	} else {
+		user, err = s.userRepo.GetByID(ctx, nil, userID)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// newRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/oidc"
+	"github.com/timebook/backend/internal/password"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// OIDC errors
+var (
+	ErrOIDCDisabled         = apperrors.New("OIDC_DISABLED", "Single sign-on is not configured", http.StatusNotFound)
+	ErrInvalidOIDCState     = apperrors.New("INVALID_OIDC_STATE", "Invalid or expired sign-on request", http.StatusBadRequest)
+	ErrOIDCLoginFailed      = apperrors.New("OIDC_LOGIN_FAILED", "Sign-on with the identity provider failed", http.StatusBadGateway)
+	ErrOIDCEmailUnverified  = apperrors.New("OIDC_EMAIL_UNVERIFIED", "The identity provider did not confirm your email address", http.StatusForbidden)
+	ErrOIDCSignupNotAllowed = apperrors.New("OIDC_SIGNUP_DISABLED", "No Timebook account exists for this email address", http.StatusForbidden)
+)
+
+// OIDCService signs users in through an external OpenID Connect provider
+type OIDCService struct {
+	provider     *oidc.Provider
+	stateRepo    repositories.OIDCStateRepository
+	identityRepo repositories.UserIdentityRepository
+	userRepo     repositories.UserRepository
+	guestRepo    repositories.GuestRepository
+	passwords    *password.Policy
+	txManager    *transaction.Manager
+	config       *config.Config
+}
+
+// NewOIDCService creates a new OIDC service. A nil provider disables single sign-on.
+func NewOIDCService(
+	provider *oidc.Provider,
+	stateRepo repositories.OIDCStateRepository,
+	identityRepo repositories.UserIdentityRepository,
+	userRepo repositories.UserRepository,
+	guestRepo repositories.GuestRepository,
+	passwords *password.Policy,
+	txManager *transaction.Manager,
+	cfg *config.Config,
+) *OIDCService {
+	return &OIDCService{
+		provider:     provider,
+		stateRepo:    stateRepo,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		guestRepo:    guestRepo,
+		passwords:    passwords,
+		txManager:    txManager,
+		config:       cfg,
+	}
+}
+
+// StartLogin records a new sign-on attempt and returns the provider URL the
+// user must be sent to
+func (s *OIDCService) StartLogin(ctx context.Context) (string, error) {
+	if s.provider == nil {
+		return "", ErrOIDCDisabled
+	}
+
+	state, err := oidc.NewNonce()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		return "", ErrOIDCLoginFailed
+	}
+
+	now := time.Now()
+	if err := s.stateRepo.DeleteExpired(ctx, nil, now); err != nil {
+		log.Printf("oidc: failed to delete expired login states: %v", err)
+	}
+	if err := s.stateRepo.Create(ctx, nil, &models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(s.config.OIDCStateTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// CompleteLogin handles the provider's callback: it consumes the state,
+// redeems the code and returns the Timebook user for the provider account,
+// linking or creating one as needed
+func (s *OIDCService) CompleteLogin(ctx context.Context, state, code string) (*models.User, error) {
+	if s.provider == nil {
+		return nil, ErrOIDCDisabled
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidOIDCState
+	}
+
+	// Consume the state first so a code can only be redeemed once
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		loginState, err := s.stateRepo.GetByHashForUpdate(ctx, tx, hashToken(state))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidOIDCState
+			}
+			return nil, err
+		}
+		if loginState.UsedAt != nil || time.Now().After(loginState.ExpiresAt) {
+			return nil, ErrInvalidOIDCState
+		}
+
+		now := time.Now()
+		loginState.UsedAt = &now
+		if err := s.stateRepo.Update(ctx, tx, loginState); err != nil {
+			return nil, err
+		}
+		return loginState, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	loginState := result.(*models.OIDCLoginState)
+
+	idToken, err := s.provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	result, err = s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		return s.resolveUser(ctx, tx, idToken)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
+
+// resolveUser finds the user linked to the provider account. Unlinked
+// accounts are matched to an existing user by verified email, or get a new
+// user account when sign-up is allowed.
+func (s *OIDCService) resolveUser(ctx context.Context, tx *gorm.DB, idToken *oidc.IDToken) (*models.User, error) {
+	now := time.Now()
+
+	identity, err := s.identityRepo.GetByIssuerSubject(ctx, tx, idToken.Issuer, idToken.Subject)
+	if err == nil {
+		identity.LastLoginAt = &now
+		if idToken.Email != "" {
+			identity.Email = idToken.Email
+		}
+		if err := s.identityRepo.Update(ctx, tx, identity); err != nil {
+			return nil, err
+		}
+		return s.userRepo.GetByID(ctx, tx, identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(idToken.Email)
+	if email == "" || !idToken.EmailVerified {
+		return nil, ErrOIDCEmailUnverified
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, tx, email)
+	switch {
+	case err == nil:
+		// The provider vouches for the address, so it counts as verified here too
+		if user.VerifiedAt == nil {
+			user.VerifiedAt = &now
+			if err := s.userRepo.Update(ctx, tx, user); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !s.config.OIDCAllowSignup {
+			return nil, ErrOIDCSignupNotAllowed
+		}
+		user, err = s.createUser(ctx, tx, idToken, email, now)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if _, err := s.guestRepo.ClaimByEmail(ctx, tx, email, user.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.Create(ctx, tx, &models.UserIdentity{
+		UserID:      user.ID,
+		Issuer:      idToken.Issuer,
+		Subject:     idToken.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// createUser creates a client account for a new provider user. The random
+// password cannot be used to log in until the user resets it, so the account
+// is marked as having none.
+func (s *OIDCService) createUser(ctx context.Context, tx *gorm.DB, idToken *oidc.IDToken, email string, now time.Time) (*models.User, error) {
+	randomPassword, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.passwords.Hash(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(idToken.Name)
+	if name == "" {
+		name = email
+	}
+
+	user := &models.User{
+		Email:         email,
+		Password:      hashedPassword,
+		PasswordUnset: true,
+		Name:          name,
+		Role:          models.RoleUser,
+		VerifiedAt:    &now,
+	}
+	if err := s.userRepo.Create(ctx, tx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/notify"
+	"github.com/timebook/backend/internal/phone"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// Phone login errors
+var (
+	ErrInvalidPhone = apperrors.New("INVALID_PHONE", "Enter a valid phone number including the country code, e.g. +447700900123", http.StatusBadRequest)
+	ErrInvalidOTP   = apperrors.New("INVALID_OTP", "Invalid or expired code", http.StatusUnauthorized)
+	ErrPhoneTaken   = apperrors.New("PHONE_TAKEN", "This phone number is already used by another account", http.StatusConflict)
+)
+
+// PhoneLoginService signs users in with one-time codes texted to the phone
+// number on their account
+type PhoneLoginService struct {
+	codeRepo  repositories.PhoneLoginCodeRepository
+	userRepo  repositories.UserRepository
+	sms       notify.SMSSender
+	txManager *transaction.Manager
+	config    *config.Config
+}
+
+// NewPhoneLoginService creates a new phone login service
+func NewPhoneLoginService(
+	codeRepo repositories.PhoneLoginCodeRepository,
+	userRepo repositories.UserRepository,
+	sms notify.SMSSender,
+	txManager *transaction.Manager,
+	cfg *config.Config,
+) *PhoneLoginService {
+	return &PhoneLoginService{
+		codeRepo:  codeRepo,
+		userRepo:  userRepo,
+		sms:       sms,
+		txManager: txManager,
+		config:    cfg,
+	}
+}
+
+// NormalizePhone converts an optional, user-entered phone number to E.164.
+// An empty number stays empty.
+func (s *PhoneLoginService) NormalizePhone(raw string) (string, error) {
+	return normalizePhone(raw, s.config.PhoneDefaultCountryCode)
+}
+
+// RequestCode texts a login code to the phone number. To avoid revealing
+// which numbers are registered, it behaves the same whether or not an account
+// uses the number, including for rate limiting. When a limit is hit the
+// returned duration says how long the caller should wait.
+func (s *PhoneLoginService) RequestCode(ctx context.Context, rawPhone, ip string) (time.Duration, error) {
+	number, err := s.NormalizePhone(rawPhone)
+	if err != nil {
+		return 0, err
+	}
+	if number == "" {
+		return 0, ErrInvalidPhone
+	}
+
+	now := time.Now()
+	if wait, err := s.sendWait(ctx, number, ip, now); err != nil || wait > 0 {
+		return wait, err
+	}
+
+	code, err := newOTP()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		if err := s.codeRepo.InvalidateForPhone(ctx, tx, number); err != nil {
+			return nil, err
+		}
+		return nil, s.codeRepo.Create(ctx, tx, &models.PhoneLoginCode{
+			Phone:     number,
+			CodeHash:  hashOTP(number, code),
+			IPAddress: ip,
+			ExpiresAt: now.Add(s.config.PhoneOTPTTL),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Codes older than the rate limit window are no longer needed
+	retention := s.config.PhoneOTPSendWindow
+	if s.config.PhoneOTPTTL > retention {
+		retention = s.config.PhoneOTPTTL
+	}
+	if err := s.codeRepo.DeleteCreatedBefore(ctx, nil, now.Add(-retention)); err != nil {
+		log.Printf("phone login: failed to delete old codes: %v", err)
+	}
+
+	user, err := s.userForPhone(ctx, number)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOTP) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	msg := notify.SMS{
+		To:   number,
+		Body: fmt.Sprintf("Your Timebook login code is %s. It expires in %s.", code, s.config.PhoneOTPTTL),
+	}
+	if err := s.sms.SendSMS(ctx, msg); err != nil {
+		// Do not reveal delivery failures to the caller
+		log.Printf("phone login: failed to text user %d: %v", user.ID, err)
+	}
+
+	return 0, nil
+}
+
+// VerifyCode checks a login code and returns the user it signs in. A code
+// can be used once and is burned after too many wrong guesses.
+func (s *PhoneLoginService) VerifyCode(ctx context.Context, rawPhone, code string) (*models.User, error) {
+	number, err := s.NormalizePhone(rawPhone)
+	if err != nil || number == "" {
+		return nil, ErrInvalidOTP
+	}
+	code = strings.TrimSpace(code)
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		now := time.Now()
+		stored, err := s.codeRepo.GetActiveForUpdate(ctx, tx, number, now)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return false, nil
+			}
+			return nil, err
+		}
+
+		// The attempt is counted even when the code is wrong, so the
+		// transaction must commit either way
+		stored.Attempts++
+		matched := stored.CodeHash == hashOTP(number, code)
+		if matched || stored.Attempts >= s.config.PhoneOTPMaxAttempts {
+			stored.UsedAt = &now
+		}
+		if err := s.codeRepo.Update(ctx, tx, stored); err != nil {
+			return nil, err
+		}
+		return matched, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !result.(bool) {
+		return nil, ErrInvalidOTP
+	}
+
+	return s.userForPhone(ctx, number)
+}
+
+// sendWait applies the resend interval and the per-number and per-address
+// sending limits
+func (s *PhoneLoginService) sendWait(ctx context.Context, number, ip string, now time.Time) (time.Duration, error) {
+	latest, err := s.codeRepo.GetLatest(ctx, nil, number)
+	if err == nil {
+		if wait := latest.CreatedAt.Add(s.config.PhoneOTPResendInterval).Sub(now); wait > 0 {
+			return wait, nil
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	since := now.Add(-s.config.PhoneOTPSendWindow)
+	sent, err := s.codeRepo.CountForPhoneSince(ctx, nil, number, since)
+	if err != nil {
+		return 0, err
+	}
+	if s.config.PhoneOTPMaxSendsPerPhone > 0 && sent >= int64(s.config.PhoneOTPMaxSendsPerPhone) {
+		return s.config.PhoneOTPSendWindow, nil
+	}
+
+	sent, err = s.codeRepo.CountForIPSince(ctx, nil, ip, since)
+	if err != nil {
+		return 0, err
+	}
+	if s.config.PhoneOTPMaxSendsPerIP > 0 && sent >= int64(s.config.PhoneOTPMaxSendsPerIP) {
+		return s.config.PhoneOTPSendWindow, nil
+	}
+
+	return 0, nil
+}
+
+// userForPhone returns the single account using the phone number. Numbers
+// shared by several accounts cannot be used to log in.
+func (s *PhoneLoginService) userForPhone(ctx context.Context, number string) (*models.User, error) {
+	users, err := s.userRepo.ListByPhone(ctx, nil, number)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) != 1 {
+		return nil, ErrInvalidOTP
+	}
+	return users[0], nil
+}
+
+// CheckPhoneFree returns ErrPhoneTaken when the normalized number is on an
+// account other than userID; pass 0 for an account not created yet
+func (s *PhoneLoginService) CheckPhoneFree(ctx context.Context, number string, userID uint) error {
+	return checkPhoneFree(ctx, nil, s.userRepo, number, userID)
+}
+
+// checkPhoneFree rejects a phone number already on another account. Phone
+// login refuses numbers shared by several accounts, so taking someone
+// else's number would lock them out of it.
+func checkPhoneFree(ctx context.Context, tx *gorm.DB, userRepo repositories.UserRepository, number string, userID uint) error {
+	if number == "" {
+		return nil
+	}
+	users, err := userRepo.ListByPhone(ctx, tx, number)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.ID != userID {
+			return ErrPhoneTaken
+		}
+	}
+	return nil
+}
+
+// normalizePhone converts an optional phone number to E.164
+func normalizePhone(raw, defaultCountryCode string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	number, err := phone.Normalize(raw, defaultCountryCode)
+	if err != nil {
+		return "", ErrInvalidPhone
+	}
+	return number, nil
+}
+
+// newOTP returns a random six-digit code
+func newOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashOTP binds a code to the number it was sent to before hashing it
+func hashOTP(number, code string) string {
+	return hashToken(number + ":" + code)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// phoneUserRepo answers ListByPhone from a fixed list of users
+type phoneUserRepo struct {
+	repositories.UserRepository
+	users []*models.User
+}
+
+func (r *phoneUserRepo) ListByPhone(ctx context.Context, tx *gorm.DB, phone string) ([]*models.User, error) {
+	var users []*models.User
+	for _, user := range r.users {
+		if user.Phone == phone {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func TestCheckPhoneFree(t *testing.T) {
+	repo := &phoneUserRepo{users: []*models.User{
+		{ID: 1, Phone: "+447700900123"},
+	}}
+
+	tests := []struct {
+		name    string
+		number  string
+		userID  uint
+		wantErr error
+	}{
+		{"unused number", "+447700900999", 2, nil},
+		{"own number", "+447700900123", 1, nil},
+		{"another account's number", "+447700900123", 2, ErrPhoneTaken},
+		{"new account taking a number", "+447700900123", 0, ErrPhoneTaken},
+		{"no number", "", 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPhoneFree(context.Background(), nil, repo, tt.number, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkPhoneFree = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/password"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// deletedUserName replaces the name of a deleted account
+const deletedUserName = "Deleted user"
+
+// accountDeletedReason is recorded on appointments cancelled by an account deletion
+const accountDeletedReason = "Account deleted"
+
+// Account deletion errors
+var (
+	ErrAdminAccountDeletion = apperrors.New("ADMIN_ACCOUNT_DELETION", "Admin accounts cannot be deleted by their owner", http.StatusConflict)
+	ErrReauthRequired       = apperrors.New("REAUTHENTICATION_REQUIRED",
+		"Your account has no password. Sign in again with your identity provider or a phone code and delete your account right after, or reset your password first.",
+		http.StatusUnauthorized)
+)
+
+// AccountExport is everything Timebook stores about a user, as returned by
+// a data export request
+type AccountExport struct {
+	ExportedAt       time.Time              `json:"exported_at"`
+	Profile          *models.User           `json:"profile"`
+	Roles            []models.UserRole      `json:"roles"`
+	Appointments     []*models.Appointment  `json:"appointments"`
+	Master           *MasterExport          `json:"master,omitempty"`
+	Sessions         []*models.Session      `json:"sessions"`
+	LinkedIdentities []*models.UserIdentity `json:"linked_identities"`
+	APIKeys          []*models.APIKey       `json:"api_keys,omitempty"`
+}
+
+// MasterExport is the master-specific part of an account export
+type MasterExport struct {
+	Profile   *models.MasterProfile `json:"profile"`
+	Services  []*models.Service     `json:"services"`
+	TimeSlots []*models.TimeSlot    `json:"time_slots"`
+}
+
+// PrivacyService handles data export and deletion requests from users
+type PrivacyService struct {
+	userRepo         repositories.UserRepository
+	appointmentRepo  repositories.AppointmentRepository
+	masterRepo       repositories.MasterRepository
+	serviceRepo      repositories.ServiceRepository
+	timeslotRepo     repositories.TimeslotRepository
+	transitionRepo   repositories.AppointmentTransitionRepository
+	sessionRepo      repositories.SessionRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	userTokenRepo    repositories.UserTokenRepository
+	identityRepo     repositories.UserIdentityRepository
+	apiKeyRepo       repositories.APIKeyRepository
+	recoveryCodeRepo repositories.RecoveryCodeRepository
+	roleGrantRepo    repositories.RoleGrantRepository
+	guestRepo        repositories.GuestRepository
+	auditRepo        repositories.AuditRepository
+	passwords        *password.Policy
+	txManager        *transaction.Manager
+	config           *config.Config
+}
+
+// NewPrivacyService creates a new privacy service
+func NewPrivacyService(
+	userRepo repositories.UserRepository,
+	appointmentRepo repositories.AppointmentRepository,
+	masterRepo repositories.MasterRepository,
+	serviceRepo repositories.ServiceRepository,
+	timeslotRepo repositories.TimeslotRepository,
+	transitionRepo repositories.AppointmentTransitionRepository,
+	sessionRepo repositories.SessionRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	userTokenRepo repositories.UserTokenRepository,
+	identityRepo repositories.UserIdentityRepository,
+	apiKeyRepo repositories.APIKeyRepository,
+	recoveryCodeRepo repositories.RecoveryCodeRepository,
+	roleGrantRepo repositories.RoleGrantRepository,
+	guestRepo repositories.GuestRepository,
+	auditRepo repositories.AuditRepository,
+	passwords *password.Policy,
+	txManager *transaction.Manager,
+	cfg *config.Config,
+) *PrivacyService {
+	return &PrivacyService{
+		userRepo:         userRepo,
+		appointmentRepo:  appointmentRepo,
+		masterRepo:       masterRepo,
+		serviceRepo:      serviceRepo,
+		timeslotRepo:     timeslotRepo,
+		transitionRepo:   transitionRepo,
+		sessionRepo:      sessionRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		userTokenRepo:    userTokenRepo,
+		identityRepo:     identityRepo,
+		apiKeyRepo:       apiKeyRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		roleGrantRepo:    roleGrantRepo,
+		guestRepo:        guestRepo,
+		auditRepo:        auditRepo,
+		passwords:        passwords,
+		txManager:        txManager,
+		config:           cfg,
+	}
+}
+
+// ExportData collects the user's profile, bookings, sign-in data and, for
+// masters, their services and time slots
+func (s *PrivacyService) ExportData(ctx context.Context, userID uint) (*AccountExport, error) {
+	user, err := s.userRepo.GetByID(ctx, nil, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	export := &AccountExport{
+		ExportedAt: time.Now(),
+		Profile:    user,
+		Roles:      user.Roles(),
+	}
+
+	if export.Appointments, err = s.appointmentRepo.List(ctx, nil, map[string]interface{}{"user_id": user.ID}); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = s.sessionRepo.ListActiveForUser(ctx, nil, user.ID); err != nil {
+		return nil, err
+	}
+	if export.LinkedIdentities, err = s.identityRepo.ListForUser(ctx, nil, user.ID); err != nil {
+		return nil, err
+	}
+	if export.APIKeys, err = s.apiKeyRepo.ListForUser(ctx, nil, user.ID); err != nil {
+		return nil, err
+	}
+
+	profile, err := s.masterRepo.GetByUserID(ctx, nil, user.ID)
+	switch {
+	case err == nil:
+		master := &MasterExport{Profile: profile}
+		if master.Services, err = s.serviceRepo.ListForMaster(ctx, nil, profile.ID); err != nil {
+			return nil, err
+		}
+		if master.TimeSlots, err = s.timeslotRepo.ListForMaster(ctx, nil, profile.ID); err != nil {
+			return nil, err
+		}
+		export.Master = master
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// DeleteAccount anonymizes the user after checking their password, or a
+// recent sign-in for accounts without one; sessionID names the caller's
+// session. The account row and its appointments are kept so masters'
+// records stay complete, but every piece of personal data is removed,
+// upcoming bookings are cancelled and the user is signed out everywhere. A
+// master's services are withdrawn so they can no longer be booked.
+func (s *PrivacyService) DeleteAccount(ctx context.Context, userID uint, currentPassword, sessionID, ip string) error {
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.userRepo.GetByIDForUpdate(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if user.HasRole(models.RoleAdmin) {
+			return nil, ErrAdminAccountDeletion
+		}
+		if err := s.reauthenticate(ctx, tx, user, currentPassword, sessionID); err != nil {
+			return nil, err
+		}
+
+		if err := s.cancelUpcoming(ctx, tx, map[string]interface{}{"user_id": user.ID}, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.appointmentRepo.ClearNotesForUser(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.withdrawMaster(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+
+		if _, err := revokeUserSessions(ctx, tx, s.sessionRepo, s.refreshTokenRepo, user.ID, ""); err != nil {
+			return nil, err
+		}
+		if err := s.refreshTokenRepo.RevokeAllForUser(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+		for _, purpose := range []models.TokenPurpose{models.TokenPurposePasswordReset, models.TokenPurposeEmailVerification} {
+			if err := s.userTokenRepo.InvalidateForUser(ctx, tx, user.ID, purpose); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.identityRepo.DeleteForUser(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.apiKeyRepo.RevokeAllForUser(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.recoveryCodeRepo.DeleteForUser(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.roleGrantRepo.DeleteForUser(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.guestRepo.AnonymizeClaimedBy(ctx, tx, user.ID, deletedUserName); err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		user.Email = fmt.Sprintf("deleted-%d@users.invalid", user.ID)
+		user.Password = ""
+		user.Name = deletedUserName
+		user.Phone = ""
+		user.VerifiedAt = nil
+		user.TOTPSecret = ""
+		user.TOTPEnabledAt = nil
+		user.TOTPLastStep = 0
+		user.AnonymizedAt = &now
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		return nil, s.auditRepo.Create(ctx, tx, &models.AuditLog{
+			ActorID:    &user.ID,
+			Action:     models.AuditAccountDeleted,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+			IPAddress:  ip,
+		})
+	})
+	return err
+}
+
+// withdrawMaster clears a master's profile, cancels their upcoming
+// appointments and removes their services and free time slots
+func (s *PrivacyService) withdrawMaster(ctx context.Context, tx *gorm.DB, userID uint) error {
+	profile, err := s.masterRepo.GetByUserID(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.cancelUpcoming(ctx, tx, map[string]interface{}{"master_id": profile.ID}, userID); err != nil {
+		return err
+	}
+	if err := s.serviceRepo.DeleteForMaster(ctx, tx, profile.ID); err != nil {
+		return err
+	}
+	if err := s.timeslotRepo.DeleteUnbookedForMaster(ctx, tx, profile.ID); err != nil {
+		return err
+	}
+
+	profile.Bio = ""
+	profile.Specialty = ""
+	profile.Experience = 0
+	return s.masterRepo.Update(ctx, tx, profile)
+}
+
+// reauthenticate checks the user confirmed their identity before deleting
+// their account: with their password or, for accounts that have none they
+// know, by signing in to the current session within the last
+// ReauthMaxAge, through their identity provider or a phone code
+func (s *PrivacyService) reauthenticate(ctx context.Context, tx *gorm.DB, user *models.User, currentPassword, sessionID string) error {
+	if currentPassword != "" && s.passwords.Compare(user.Password, currentPassword) {
+		return nil
+	}
+	if !user.PasswordUnset {
+		return ErrIncorrectPassword
+	}
+
+	if sessionID == "" {
+		return ErrReauthRequired
+	}
+	session, err := s.sessionRepo.GetBySessionID(ctx, tx, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrReauthRequired
+		}
+		return err
+	}
+	if session.UserID != user.ID || session.RevokedAt != nil || time.Since(session.CreatedAt) > s.config.ReauthMaxAge {
+		return ErrReauthRequired
+	}
+	return nil
+}
+
+// cancelUpcoming cancels, on behalf of the deleted user, the pending and
+// confirmed appointments matching the filters that have not started yet,
+// freeing their time slots
+func (s *PrivacyService) cancelUpcoming(ctx context.Context, tx *gorm.DB, filters map[string]interface{}, userID uint) error {
+	appointments, err := s.appointmentRepo.List(ctx, tx, filters)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, listed := range appointments {
+		// Lock and re-read, as a master may confirm or reject it meanwhile
+		appointment, err := s.appointmentRepo.GetByIDForUpdate(ctx, tx, listed.ID)
+		if err != nil {
+			return err
+		}
+		if !appointment.StartTime.After(now) {
+			continue
+		}
+		if !canTransition(appointment.Status, models.StatusCancelled) {
+			continue
+		}
+
+		if err := cancelAppointment(ctx, tx, s.appointmentRepo, s.timeslotRepo, s.transitionRepo, appointment, &userID, accountDeletedReason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/timebook/backend/internal/authz"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// Role management errors
+var (
+	ErrOwnRoles       = apperrors.New("OWN_ROLES", "You cannot change your own roles", http.StatusForbidden)
+	ErrPrimaryRole    = apperrors.New("PRIMARY_ROLE", "A user's primary role cannot be revoked", http.StatusConflict)
+	ErrRoleNotGranted = apperrors.New("ROLE_NOT_GRANTED", "The user does not have this role", http.StatusNotFound)
+)
+
+// RoleService grants and revokes additional roles on user accounts
+type RoleService struct {
+	roleGrantRepo repositories.RoleGrantRepository
+	userRepo      repositories.UserRepository
+	masterRepo    repositories.MasterRepository
+	auditRepo     repositories.AuditRepository
+	txManager     *transaction.Manager
+}
+
+// NewRoleService creates a new role service
+func NewRoleService(
+	roleGrantRepo repositories.RoleGrantRepository,
+	userRepo repositories.UserRepository,
+	masterRepo repositories.MasterRepository,
+	auditRepo repositories.AuditRepository,
+	txManager *transaction.Manager,
+) *RoleService {
+	return &RoleService{
+		roleGrantRepo: roleGrantRepo,
+		userRepo:      userRepo,
+		masterRepo:    masterRepo,
+		auditRepo:     auditRepo,
+		txManager:     txManager,
+	}
+}
+
+// GrantRole gives the user an additional role. Granting the master role also
+// creates the user's master profile if they do not have one yet.
+func (s *RoleService) GrantRole(ctx context.Context, adminID, userID uint, role models.UserRole, ip string) (*models.User, error) {
+	if !authz.IsRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if adminID == userID {
+		return nil, ErrOwnRoles
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.userRepo.GetByIDForUpdate(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if user.HasRole(role) {
+			return user, nil
+		}
+
+		grant := &models.RoleGrant{UserID: user.ID, Role: role, GrantedByID: &adminID}
+		if err := s.roleGrantRepo.Create(ctx, tx, grant); err != nil {
+			return nil, err
+		}
+		user.RoleGrants = append(user.RoleGrants, *grant)
+
+		if role == models.RoleMaster {
+			if _, err := s.masterRepo.GetByUserID(ctx, tx, user.ID); errors.Is(err, gorm.ErrRecordNotFound) {
+				if err := s.masterRepo.Create(ctx, tx, &models.MasterProfile{UserID: user.ID}); err != nil {
+					return nil, err
+				}
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		return user, s.audit(ctx, tx, models.AuditRoleGranted, adminID, user.ID, role, ip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
+
+// RevokeRole removes a role previously granted to the user. The primary role
+// is part of the account itself and cannot be revoked.
+func (s *RoleService) RevokeRole(ctx context.Context, adminID, userID uint, role models.UserRole, ip string) (*models.User, error) {
+	if !authz.IsRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if adminID == userID {
+		return nil, ErrOwnRoles
+	}
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.userRepo.GetByIDForUpdate(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if user.Role == role {
+			return nil, ErrPrimaryRole
+		}
+
+		deleted, err := s.roleGrantRepo.Delete(ctx, tx, user.ID, role)
+		if err != nil {
+			return nil, err
+		}
+		if !deleted {
+			return nil, ErrRoleNotGranted
+		}
+
+		grants := user.RoleGrants[:0]
+		for _, grant := range user.RoleGrants {
+			if grant.Role != role {
+				grants = append(grants, grant)
+			}
+		}
+		user.RoleGrants = grants
+
+		return user, s.audit(ctx, tx, models.AuditRoleRevoked, adminID, user.ID, role, ip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
+
+func (s *RoleService) audit(ctx context.Context, tx *gorm.DB, action string, adminID, userID uint, role models.UserRole, ip string) error {
+	return s.auditRepo.Create(ctx, tx, &models.AuditLog{
+		ActorID:    &adminID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		IPAddress:  ip,
+		Details:    "role=" + string(role),
+	})
+}
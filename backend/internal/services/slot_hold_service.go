@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/timebook/backend/internal/config"
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// Slot hold errors
+var (
+	ErrHoldNotFound = apperrors.New("NOT_FOUND", "Hold not found", http.StatusNotFound)
+	ErrHoldExpired  = apperrors.New("HOLD_EXPIRED", "Your hold on this time has expired. Please choose a time again.", http.StatusConflict)
+	ErrHoldMismatch = apperrors.New("HOLD_MISMATCH", "The booking does not match the time you are holding", http.StatusConflict)
+)
+
+// SlotHoldService lets a client reserve a time for a short while so nobody
+// else can book it while they finish their booking
+type SlotHoldService struct {
+	holdRepo     repositories.SlotHoldRepository
+	serviceRepo  repositories.ServiceRepository
+	masterRepo   repositories.MasterRepository
+	userRepo     repositories.UserRepository
+	availability *AvailabilityService
+	txManager    *transaction.Manager
+	config       *config.Config
+}
+
+// NewSlotHoldService creates a new slot hold service
+func NewSlotHoldService(
+	holdRepo repositories.SlotHoldRepository,
+	serviceRepo repositories.ServiceRepository,
+	masterRepo repositories.MasterRepository,
+	userRepo repositories.UserRepository,
+	availability *AvailabilityService,
+	txManager *transaction.Manager,
+	cfg *config.Config,
+) *SlotHoldService {
+	return &SlotHoldService{
+		holdRepo:     holdRepo,
+		serviceRepo:  serviceRepo,
+		masterRepo:   masterRepo,
+		userRepo:     userRepo,
+		availability: availability,
+		txManager:    txManager,
+		config:       cfg,
+	}
+}
+
+// Create holds the time of the booking for the user until the hold
+// expires. A user holds one time at most, so any earlier hold is released.
+// Holding needs a verified email whenever booking does.
+func (s *SlotHoldService) Create(ctx context.Context, userID uint, booking Booking) (*models.SlotHold, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		if err := checkEmailVerified(ctx, tx, s.userRepo, s.config, userID); err != nil {
+			return nil, err
+		}
+
+		service, err := s.serviceRepo.GetByID(ctx, tx, booking.ServiceID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrServiceNotFound
+			}
+			return nil, err
+		}
+		duration, err := serviceDuration(service, booking.ServiceOptionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.holdRepo.DeleteForUser(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+
+		// Lock the master so the time cannot be booked or held concurrently
+		master, err := s.masterRepo.GetByIDForUpdate(ctx, tx, service.MasterID)
+		if err != nil {
+			return nil, err
+		}
+		start := booking.StartTime
+		end := start.Add(duration)
+		if err := s.availability.CheckFree(ctx, tx, master, start, end, Ignore{}); err != nil {
+			return nil, err
+		}
+
+		hold := &models.SlotHold{
+			UserID:          userID,
+			MasterID:        master.ID,
+			ServiceID:       service.ID,
+			ServiceOptionID: booking.ServiceOptionID,
+			StartTime:       start,
+			EndTime:         end,
+			ExpiresAt:       time.Now().Add(s.config.SlotHoldTTL),
+		}
+		if err := s.holdRepo.Create(ctx, tx, hold); err != nil {
+			return nil, err
+		}
+		return hold, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.SlotHold), nil
+}
+
+// Release gives up one of the user's holds before it expires
+func (s *SlotHoldService) Release(ctx context.Context, userID, holdID uint) error {
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		hold, err := s.holdRepo.GetByIDForUpdate(ctx, tx, holdID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrHoldNotFound
+			}
+			return nil, err
+		}
+		if hold.UserID != userID {
+			return nil, ErrHoldNotFound
+		}
+		return nil, s.holdRepo.Delete(ctx, tx, hold.ID)
+	})
+	return err
+}
+
+// DeleteExpired removes holds that have run out. Expired holds no longer
+// block anyone, so this only keeps the table small. It is run periodically
+// by a background job.
+func (s *SlotHoldService) DeleteExpired(ctx context.Context) error {
+	deleted, err := s.holdRepo.DeleteExpired(ctx, nil, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("slot holds: deleted %d expired", deleted)
+	}
+	return nil
+}
+
+// checkHoldMatches returns an error unless the hold belongs to the client of
+// the appointment being booked, is still active and is for the same service
+// and time
+func checkHoldMatches(hold *models.SlotHold, appointment *models.Appointment, service *models.Service, booking Booking) error {
+	if appointment.UserID == nil || *appointment.UserID != hold.UserID {
+		return ErrHoldNotFound
+	}
+	if !hold.ExpiresAt.After(time.Now()) {
+		return ErrHoldExpired
+	}
+	if hold.ServiceID != service.ID || !hold.StartTime.Equal(booking.StartTime) || !sameOption(hold.ServiceOptionID, booking.ServiceOptionID) {
+		return ErrHoldMismatch
+	}
+	return nil
+}
+
+// sameOption reports whether two optional service option IDs are equal
+func sameOption(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
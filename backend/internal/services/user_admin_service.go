@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/timebook/backend/internal/errors"
+	"github.com/timebook/backend/internal/models"
+	"github.com/timebook/backend/internal/repositories"
+	"github.com/timebook/backend/internal/transaction"
+	"gorm.io/gorm"
+)
+
+// User management errors
+var (
+	ErrManageOwnAccount = apperrors.New("OWN_ACCOUNT", "You cannot suspend or delete your own account", http.StatusForbidden)
+	ErrUserDeleted      = apperrors.New("USER_DELETED", "The user has been deleted", http.StatusConflict)
+	ErrUserNotDeleted   = apperrors.New("USER_NOT_DELETED", "The user has not been deleted", http.StatusConflict)
+	ErrInvalidStatus    = apperrors.New("INVALID_STATUS", "Status must be one of active, suspended or deleted", http.StatusBadRequest)
+)
+
+// UserDetail is everything an admin sees about a single user
+type UserDetail struct {
+	User          *models.User
+	Roles         []models.UserRole
+	MasterProfile *models.MasterProfile
+	Sessions      []*models.Session
+}
+
+// UserAdminService lets admins find, suspend and delete user accounts
+type UserAdminService struct {
+	userRepo         repositories.UserRepository
+	masterRepo       repositories.MasterRepository
+	sessionRepo      repositories.SessionRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	auditRepo        repositories.AuditRepository
+	txManager        *transaction.Manager
+}
+
+// NewUserAdminService creates a new user admin service
+func NewUserAdminService(
+	userRepo repositories.UserRepository,
+	masterRepo repositories.MasterRepository,
+	sessionRepo repositories.SessionRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	auditRepo repositories.AuditRepository,
+	txManager *transaction.Manager,
+) *UserAdminService {
+	return &UserAdminService{
+		userRepo:         userRepo,
+		masterRepo:       masterRepo,
+		sessionRepo:      sessionRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		auditRepo:        auditRepo,
+		txManager:        txManager,
+	}
+}
+
+// ListUsers returns a page of users matching the filter and the total
+// number of matches
+func (s *UserAdminService) ListUsers(ctx context.Context, filter repositories.UserFilter) ([]*models.User, int64, error) {
+	switch filter.Status {
+	case "", repositories.UserStatusActive, repositories.UserStatusSuspended, repositories.UserStatusDeleted:
+	default:
+		return nil, 0, ErrInvalidStatus
+	}
+	return s.userRepo.Search(ctx, nil, filter)
+}
+
+// GetUser returns a user's account, roles, master profile and active
+// sessions. Deleted users can be looked up too.
+func (s *UserAdminService) GetUser(ctx context.Context, userID uint) (*UserDetail, error) {
+	user, err := s.userRepo.GetByIDUnscoped(ctx, nil, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	detail := &UserDetail{User: user, Roles: user.Roles()}
+	if detail.Sessions, err = s.sessionRepo.ListActiveForUser(ctx, nil, user.ID); err != nil {
+		return nil, err
+	}
+
+	profile, err := s.masterRepo.GetByUserID(ctx, nil, user.ID)
+	switch {
+	case err == nil:
+		detail.MasterProfile = profile
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	return detail, nil
+}
+
+// Suspend blocks the user from logging in and signs them out everywhere.
+// Access tokens already issued stop working on their next request.
+func (s *UserAdminService) Suspend(ctx context.Context, adminID, userID uint, reason, ip string) (*models.User, error) {
+	if adminID == userID {
+		return nil, ErrManageOwnAccount
+	}
+	reason = strings.TrimSpace(reason)
+
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.lockUser(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Suspended() {
+			return user, nil
+		}
+
+		now := time.Now()
+		user.SuspendedAt = &now
+		user.SuspendedReason = reason
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+		if err := s.signOut(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+
+		return user, s.audit(ctx, tx, adminID, models.AuditUserSuspended, user.ID, ip, fmt.Sprintf("reason=%q", reason))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
+
+// Unsuspend lets a suspended user log in again
+func (s *UserAdminService) Unsuspend(ctx context.Context, adminID, userID uint, ip string) (*models.User, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.lockUser(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !user.Suspended() {
+			return user, nil
+		}
+
+		user.SuspendedAt = nil
+		user.SuspendedReason = ""
+		if err := s.userRepo.Update(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		return user, s.audit(ctx, tx, adminID, models.AuditUserUnsuspended, user.ID, ip, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
+
+// Delete soft-deletes the user and signs them out everywhere. The account
+// and its data are kept and can be restored.
+func (s *UserAdminService) Delete(ctx context.Context, adminID, userID uint, ip string) error {
+	if adminID == userID {
+		return ErrManageOwnAccount
+	}
+
+	_, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.lockUser(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.signOut(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.userRepo.Delete(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		return nil, s.audit(ctx, tx, adminID, models.AuditUserDeleted, user.ID, ip, "")
+	})
+	return err
+}
+
+// Restore undoes a soft delete. The user has to log in again.
+func (s *UserAdminService) Restore(ctx context.Context, adminID, userID uint, ip string) (*models.User, error) {
+	result, err := s.txManager.WithTransaction(ctx, func(tx *gorm.DB) (interface{}, error) {
+		user, err := s.userRepo.GetByIDUnscopedForUpdate(ctx, tx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrNotFound
+			}
+			return nil, err
+		}
+		if !user.DeletedAt.Valid {
+			return nil, ErrUserNotDeleted
+		}
+
+		if err := s.userRepo.Restore(ctx, tx, user); err != nil {
+			return nil, err
+		}
+
+		return user, s.audit(ctx, tx, adminID, models.AuditUserRestored, user.ID, ip, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.User), nil
+}
+
+// lockUser loads a user that has not been deleted for update
+func (s *UserAdminService) lockUser(ctx context.Context, tx *gorm.DB, userID uint) (*models.User, error) {
+	user, err := s.userRepo.GetByIDUnscopedForUpdate(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, err
+	}
+	if user.DeletedAt.Valid {
+		return nil, ErrUserDeleted
+	}
+	return user, nil
+}
+
+// signOut revokes every session and refresh token of the user
+func (s *UserAdminService) signOut(ctx context.Context, tx *gorm.DB, userID uint) error {
+	if _, err := revokeUserSessions(ctx, tx, s.sessionRepo, s.refreshTokenRepo, userID, ""); err != nil {
+		return err
+	}
+	return s.refreshTokenRepo.RevokeAllForUser(ctx, tx, userID)
+}
+
+// audit records an admin action on a user
+func (s *UserAdminService) audit(ctx context.Context, tx *gorm.DB, adminID uint, action string, userID uint, ip, details string) error {
+	return s.auditRepo.Create(ctx, tx, &models.AuditLog{
+		ActorID:    &adminID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		IPAddress:  ip,
+		Details:    details,
+	})
+}
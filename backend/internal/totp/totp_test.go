@@ -0,0 +1,149 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors,
+// "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	upper, err := Code(rfcSecret, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, err := Code(strings.ToLower(rfcSecret), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upper != lower {
+		t.Errorf("lowercase secret gave %s, want %s", lower, upper)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted a secret that is not base32")
+	}
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want int64
+	}{
+		{0, 0},
+		{29, 0},
+		{30, 1},
+		{59, 1},
+		{60, 2},
+		{1111111109, 37037036},
+	}
+	for _, tt := range tests {
+		if got := Step(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("Step(%d) = %d, want %d", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", codeAt(current), 1, current, true},
+		{"previous step within skew", codeAt(current - 1), 1, current - 1, true},
+		{"next step within skew", codeAt(current + 1), 1, current + 1, true},
+		{"two steps back outside skew", codeAt(current - 2), 1, 0, false},
+		{"two steps ahead outside skew", codeAt(current + 2), 1, 0, false},
+		{"previous step without skew", codeAt(current - 1), 0, 0, false},
+		{"surrounding spaces", " " + codeAt(current) + " ", 0, current, true},
+		{"wrong code", "000000", 1, 0, false},
+		{"too short", codeAt(current)[:5], 1, 0, false},
+		{"too long", codeAt(current) + "0", 1, 0, false},
+		{"empty", "", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	if _, ok := Validate("not base32!", "123456", time.Now(), 1); ok {
+		t.Error("Validate accepted a code for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("GenerateSecret returned the same secret twice")
+	}
+	// 20 random bytes encode to 32 base32 characters
+	if len(a) != 32 {
+		t.Errorf("secret length = %d, want 32", len(a))
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Errorf("generated secret cannot be used: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Timebook", "ann@example.com", rfcSecret)
+	want := "otpauth://totp/Timebook:ann@example.com?algorithm=SHA1&digits=6&issuer=Timebook&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("URI = %s, want %s", got, want)
+	}
+}
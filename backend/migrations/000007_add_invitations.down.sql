@@ -0,0 +1,2 @@
+-- Drop invitations table
+DROP TABLE IF EXISTS invitations;
@@ -0,0 +1,16 @@
+-- Create invitations table (admin-issued, single-use role invitations)
+CREATE TABLE IF NOT EXISTS invitations (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    invited_by_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    nonce_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations(email);
@@ -0,0 +1,2 @@
+-- Remove verified_at column from users table
+ALTER TABLE users DROP COLUMN IF EXISTS verified_at;
@@ -0,0 +1,3 @@
+-- Drop audit_logs and login_throttles tables
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS login_throttles;
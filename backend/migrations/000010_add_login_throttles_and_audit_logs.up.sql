@@ -0,0 +1,29 @@
+-- Create login_throttles table (failed login tracking per account and per IP)
+CREATE TABLE IF NOT EXISTS login_throttles (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_throttles_scope_key ON login_throttles(scope, key);
+
+-- Create audit_logs table
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32),
+    target_id VARCHAR(255),
+    ip_address VARCHAR(64),
+    details TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
@@ -0,0 +1,3 @@
+-- Drop OIDC login tables
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS oidc_login_states;
@@ -0,0 +1,23 @@
+-- Create sessions table (one row per login, referenced by the "sid" token claim)
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    session_id VARCHAR(64) UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent VARCHAR(255),
+    ip_address VARCHAR(64),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- Backfill sessions for logins that still hold a live refresh token
+INSERT INTO sessions (created_at, updated_at, session_id, user_id, last_seen_at, expires_at)
+SELECT MIN(created_at), MAX(created_at), session_id, user_id, MAX(created_at), MAX(expires_at)
+FROM refresh_tokens
+WHERE revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+GROUP BY session_id, user_id
+ON CONFLICT (session_id) DO NOTHING;
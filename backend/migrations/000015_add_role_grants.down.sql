@@ -0,0 +1,2 @@
+-- Drop role_grants table
+DROP TABLE IF EXISTS role_grants;
@@ -0,0 +1,11 @@
+-- Create role_grants table (roles held in addition to users.role)
+CREATE TABLE IF NOT EXISTS role_grants (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    granted_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_role_grants_user_role ON role_grants(user_id, role);
+CREATE INDEX IF NOT EXISTS idx_role_grants_role ON role_grants(role);
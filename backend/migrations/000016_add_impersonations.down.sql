@@ -0,0 +1,2 @@
+-- Drop impersonations table
+DROP TABLE IF EXISTS impersonations;
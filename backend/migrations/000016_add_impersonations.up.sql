@@ -0,0 +1,16 @@
+-- Create impersonations table (admin support sessions acting as another user)
+CREATE TABLE IF NOT EXISTS impersonations (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    admin_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    admin_session_id VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    ip_address VARCHAR(64),
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonations_admin_id ON impersonations(admin_id);
+CREATE INDEX IF NOT EXISTS idx_impersonations_user_id ON impersonations(user_id);
@@ -0,0 +1,2 @@
+-- Remove anonymized_at column from users table
+ALTER TABLE users DROP COLUMN IF EXISTS anonymized_at;
@@ -0,0 +1,3 @@
+-- Mark accounts whose owner deleted them; the row is kept, with personal data
+-- removed, so past appointments still reference it
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
@@ -0,0 +1,2 @@
+-- Drop phone_login_codes table
+DROP TABLE IF EXISTS phone_login_codes;
@@ -0,0 +1,21 @@
+-- Create phone_login_codes table (one-time codes for passwordless phone login)
+CREATE TABLE IF NOT EXISTS phone_login_codes (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    phone VARCHAR(16) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    ip_address VARCHAR(64),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone_login_codes_phone ON phone_login_codes(phone);
+CREATE INDEX IF NOT EXISTS idx_phone_login_codes_ip_address ON phone_login_codes(ip_address);
+CREATE INDEX IF NOT EXISTS idx_phone_login_codes_created_at ON phone_login_codes(created_at);
+
+-- Phone login looks numbers up in E.164; strip formatting from numbers that
+-- were already entered in international form
+UPDATE users SET phone = '+' || regexp_replace(phone, '[^0-9]', '', 'g')
+WHERE phone LIKE '+%' AND phone ~ '[^+0-9]';
@@ -0,0 +1,7 @@
+-- Drop guest bookings; appointments that were never claimed are removed
+DELETE FROM appointments WHERE user_id IS NULL;
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS chk_appointments_client;
+DROP INDEX IF EXISTS idx_appointments_guest_id;
+ALTER TABLE appointments DROP COLUMN IF EXISTS guest_id;
+ALTER TABLE appointments ALTER COLUMN user_id SET NOT NULL;
+DROP TABLE IF EXISTS guests;
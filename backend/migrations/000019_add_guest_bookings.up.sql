@@ -0,0 +1,21 @@
+-- Create guests table (contact details of clients who book without an account)
+CREATE TABLE IF NOT EXISTS guests (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    phone VARCHAR(50),
+    claimed_by_user_id INTEGER REFERENCES users(id),
+    claimed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_guests_email ON guests(LOWER(email));
+
+-- Guest appointments have no user until the guest is claimed
+ALTER TABLE appointments ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS guest_id INTEGER REFERENCES guests(id);
+ALTER TABLE appointments ADD CONSTRAINT chk_appointments_client
+    CHECK (user_id IS NOT NULL OR guest_id IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_guest_id ON appointments(guest_id);
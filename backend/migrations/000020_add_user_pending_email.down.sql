@@ -0,0 +1,2 @@
+-- Remove pending_email column from users table
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
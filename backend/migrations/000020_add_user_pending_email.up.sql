@@ -0,0 +1,2 @@
+-- Email changes wait here until the new address is confirmed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email VARCHAR(255);
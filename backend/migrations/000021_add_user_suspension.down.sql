@@ -0,0 +1,3 @@
+-- Remove suspension columns from users table
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
@@ -0,0 +1,3 @@
+-- Accounts suspended by an admin cannot log in until unsuspended
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_reason TEXT;
@@ -0,0 +1,6 @@
+-- Remove cancellation details and policy
+ALTER TABLE master_profiles DROP COLUMN IF EXISTS cancellation_notice_hours;
+
+ALTER TABLE appointments DROP COLUMN IF EXISTS cancellation_reason;
+ALTER TABLE appointments DROP COLUMN IF EXISTS cancelled_by_id;
+ALTER TABLE appointments DROP COLUMN IF EXISTS cancelled_at;
@@ -0,0 +1,7 @@
+-- Record who cancelled an appointment and why, and let masters set how much
+-- notice clients must give
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS cancelled_by_id INTEGER REFERENCES users(id);
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS cancellation_reason TEXT;
+
+ALTER TABLE master_profiles ADD COLUMN IF NOT EXISTS cancellation_notice_hours INTEGER NOT NULL DEFAULT 0;
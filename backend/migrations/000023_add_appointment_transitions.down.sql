@@ -0,0 +1,2 @@
+-- Drop appointment_transitions table
+DROP TABLE IF EXISTS appointment_transitions;
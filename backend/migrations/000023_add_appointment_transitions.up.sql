@@ -0,0 +1,12 @@
+-- Create appointment_transitions table (history of appointment status changes)
+CREATE TABLE IF NOT EXISTS appointment_transitions (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    actor_id INTEGER REFERENCES users(id),
+    reason TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_transitions_appointment_id ON appointment_transitions(appointment_id);
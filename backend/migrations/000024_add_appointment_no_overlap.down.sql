@@ -0,0 +1,3 @@
+-- Remove the non-overlap constraint. The time columns stay TIMESTAMPTZ, which
+-- is what the application maps them to.
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
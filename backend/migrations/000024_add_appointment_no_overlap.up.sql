@@ -0,0 +1,33 @@
+-- Stop a master from having two active appointments at the same time, even
+-- when they are booked concurrently
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- tstzrange needs time zone aware columns; existing times are stored in UTC
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'appointments' AND column_name = 'start_time') = 'timestamp without time zone' THEN
+        ALTER TABLE appointments
+            ALTER COLUMN start_time TYPE TIMESTAMPTZ USING start_time AT TIME ZONE 'UTC',
+            ALTER COLUMN end_time TYPE TIMESTAMPTZ USING end_time AT TIME ZONE 'UTC';
+    END IF;
+END $$;
+
+-- Earlier versions only checked for overlaps within a service, so resolve
+-- any overlapping bookings before applying this migration
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM appointments a
+        JOIN appointments b ON a.master_id = b.master_id AND a.id < b.id
+        WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL
+          AND a.status IN ('pending', 'confirmed') AND b.status IN ('pending', 'confirmed')
+          AND a.start_time < b.end_time AND a.end_time > b.start_time
+    ) THEN
+        RAISE EXCEPTION 'overlapping pending or confirmed appointments exist; cancel or reject them first';
+    END IF;
+END $$;
+
+ALTER TABLE appointments ADD CONSTRAINT appointments_no_overlap
+    EXCLUDE USING gist (master_id WITH =, tstzrange(start_time, end_time) WITH &&)
+    WHERE (deleted_at IS NULL AND status IN ('pending', 'confirmed'));
@@ -0,0 +1,4 @@
+-- Remove master working hours
+ALTER TABLE master_profiles DROP COLUMN IF EXISTS workday_end_hour;
+ALTER TABLE master_profiles DROP COLUMN IF EXISTS workday_start_hour;
+ALTER TABLE master_profiles DROP COLUMN IF EXISTS time_zone;
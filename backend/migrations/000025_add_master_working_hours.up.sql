@@ -0,0 +1,6 @@
+-- Add master working hours, which limit when appointments can be booked.
+-- time_zone stays empty until a master sets it; the hours are then read in
+-- the server's DEFAULT_TIME_ZONE.
+ALTER TABLE master_profiles ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE master_profiles ADD COLUMN IF NOT EXISTS workday_start_hour INTEGER NOT NULL DEFAULT 8;
+ALTER TABLE master_profiles ADD COLUMN IF NOT EXISTS workday_end_hour INTEGER NOT NULL DEFAULT 22;
@@ -0,0 +1,2 @@
+-- Drop slot_holds table
+DROP TABLE IF EXISTS slot_holds;
@@ -0,0 +1,16 @@
+-- Create slot_holds table (times reserved for a client while they finish booking)
+CREATE TABLE IF NOT EXISTS slot_holds (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    master_id INTEGER NOT NULL REFERENCES master_profiles(id) ON DELETE CASCADE,
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    service_option_id INTEGER REFERENCES service_options(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_slot_holds_user_id ON slot_holds(user_id);
+CREATE INDEX IF NOT EXISTS idx_slot_holds_master_time ON slot_holds(master_id, start_time, end_time);
+CREATE INDEX IF NOT EXISTS idx_slot_holds_expires_at ON slot_holds(expires_at);
@@ -0,0 +1,4 @@
+-- Remove guest booking rate limit columns
+DROP INDEX IF EXISTS idx_guests_ip_created_at;
+DROP INDEX IF EXISTS idx_guests_email_created_at;
+ALTER TABLE guests DROP COLUMN IF EXISTS ip_address;
@@ -0,0 +1,5 @@
+-- Record where guest bookings come from so they can be rate limited
+ALTER TABLE guests ADD COLUMN IF NOT EXISTS ip_address VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_guests_email_created_at ON guests(LOWER(email), created_at);
+CREATE INDEX IF NOT EXISTS idx_guests_ip_created_at ON guests(ip_address, created_at);
@@ -0,0 +1,2 @@
+-- Remove password_unset flag
+ALTER TABLE users DROP COLUMN IF EXISTS password_unset;
@@ -0,0 +1,10 @@
+-- Mark accounts whose password is a random one the user was never told
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_unset BOOLEAN NOT NULL DEFAULT false;
+
+-- Accounts created through OpenID Connect got their identity at sign-up.
+-- Some may have reset their password since; it is still accepted then.
+UPDATE users SET password_unset = true
+WHERE EXISTS (
+    SELECT 1 FROM user_identities i
+    WHERE i.user_id = users.id AND i.created_at < users.created_at + INTERVAL '1 minute'
+);